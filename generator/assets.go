@@ -0,0 +1,170 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// The manifest of the assets used by a build, in the output directory
+const AssetManifest = "assets.json"
+
+// A static asset used by the templates
+type Asset struct {
+	URL       string // URL of the asset, fingerprinted if configured, ie. /css/main.3fa1c2d4.css
+	Integrity string // subresource integrity hash, ie. sha384-...
+}
+
+// Tell if the assets with the extension of name are fingerprinted
+func (b *Builder) fingerprinted(name string) bool {
+	ext := strings.TrimPrefix(strings.ToLower(path.Ext(name)), ".")
+	for _, e := range b.Config.Fingerprint {
+		if strings.TrimPrefix(strings.ToLower(e), ".") == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the asset of a logical path of the static directory, ie. css/main.css,
+// or of a bundle.
+// A fingerprinted copy of the asset is written to Out when configured.
+func (b *Builder) asset(name string) (*Asset, error) {
+	logical := path.Clean("/" + name)
+	if a, ok := b.assets[logical]; ok {
+		return a, nil
+	}
+	// CSS files refer to the fingerprinted assets they use
+	if b.pending[logical] {
+		return nil, fmt.Errorf("asset reference cycle through %s", logical)
+	}
+	if b.pending == nil {
+		b.pending = make(map[string]bool)
+	}
+	b.pending[logical] = true
+	defer delete(b.pending, logical)
+	content, ok := b.bundles[logical]
+	if !ok {
+		var err error
+		content, err = b.staticOutput(logical)
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("asset not found: %s", name)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	sri := sha512.Sum384(content)
+	a := &Asset{URL: logical, Integrity: "sha384-" + base64.StdEncoding.EncodeToString(sri[:])}
+	if b.fingerprinted(logical) {
+		sum := sha256.Sum256(content)
+		ext := path.Ext(logical)
+		a.URL = strings.TrimSuffix(logical, ext) + "." + hex.EncodeToString(sum[:4]) + ext
+		fpath := filepath.Join(b.PublicDir, filepath.FromSlash(a.URL))
+		if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(fpath, content, 0644); err != nil {
+			return nil, err
+		}
+		b.outputs[a.URL] = true
+	}
+	b.assets[logical] = a
+	return a, nil
+}
+
+// Replace the local URLs of a CSS file of the static roots by the ones of
+// their fingerprinted copies, ie. url(../fonts/a.woff) by
+// url(../fonts/a.3fa1c2d4.woff). The CSS files it refers to are
+// fingerprinted first.
+func (b *Builder) fingerprintCSSURLs(logical string, content []byte) ([]byte, error) {
+	return mapCSSURLs(content, func(target string) (string, error) {
+		if strings.HasPrefix(target, "#") || strings.HasPrefix(target, "//") || rxScheme.MatchString(target) {
+			return target, nil
+		}
+		suffix := ""
+		if i := strings.IndexAny(target, "?#"); i >= 0 {
+			target, suffix = target[:i], target[i:]
+		}
+		if !b.fingerprinted(target) {
+			return target + suffix, nil
+		}
+		ref := target
+		if !path.IsAbs(ref) {
+			ref = path.Join(path.Dir(logical), ref)
+		}
+		if _, ok := b.staticFile(ref); !ok {
+			return target + suffix, nil
+		}
+		a, err := b.asset(ref)
+		if err != nil {
+			return "", err
+		}
+		return path.Join(path.Dir(target), path.Base(a.URL)) + suffix, nil
+	})
+}
+
+// Template functions resolving assets: `asset "css/main.css"` returns the URL
+// of the asset, `integrity "css/main.css"` its subresource integrity hash and
+// `bundle "css/site.css"` the tag including a bundle
+func (b *Builder) assetFuncs() template.FuncMap {
+	return template.FuncMap{
+		"bundle": b.bundleTag,
+		"asset": func(name string) (string, error) {
+			a, err := b.asset(name)
+			if err != nil {
+				return "", err
+			}
+			return a.URL, nil
+		},
+		"integrity": func(name string) (string, error) {
+			a, err := b.asset(name)
+			if err != nil {
+				return "", err
+			}
+			return a.Integrity, nil
+		},
+	}
+}
+
+// Read the manifest of the previous build, if any
+func (b *Builder) readAssetManifest() map[string]*Asset {
+	var previous map[string]*Asset
+	if js, err := ioutil.ReadFile(filepath.Join(b.PublicDir, AssetManifest)); err == nil {
+		json.Unmarshal(js, &previous)
+	}
+	return previous
+}
+
+// Write the manifest of the assets used by the build, fingerprinted copies
+// of the previous build which are not used any more are removed.
+func (b *Builder) writeAssetManifest(previous map[string]*Asset) error {
+	for logical, a := range previous {
+		if cur, ok := b.assets[logical]; a.URL != logical && (!ok || cur.URL != a.URL) {
+			os.Remove(filepath.Join(b.PublicDir, filepath.FromSlash(a.URL)))
+		}
+	}
+	fpath := filepath.Join(b.PublicDir, AssetManifest)
+	if len(b.assets) == 0 {
+		os.Remove(fpath)
+		return nil
+	}
+	js, err := json.MarshalIndent(b.assets, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fpath, js, 0644)
+}
@@ -0,0 +1,139 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := writeTree(t, map[string]string{
+		"templates/default.amber": `| <link href="#{asset("css/main.css")}" />` + "\n",
+		"templates/broken.amber":  `| <link href="#{asset("css/nosuch.css")}" />` + "\n",
+		"static/css/main.css":     "body { color: black; }\n",
+		"src/page.md":             "---\nTitle: page\n---\nPage\n",
+	})
+	defer os.RemoveAll(dir)
+
+	b, err := New(Config{RootDir: dir, Src: "src", Out: "out", Template: "templates", Static: []StaticDir{{Dir: "static"}}, Fingerprint: []string{".CSS"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	first := b.assets["/css/main.css"].URL
+	if !strings.HasPrefix(first, "/css/main.") || first == "/css/main.css" {
+		t.Fatalf("expected a fingerprinted URL, got %s", first)
+	}
+	page, _ := ioutil.ReadFile(filepath.Join(dir, "out", "page"))
+	if !strings.Contains(string(page), first) {
+		t.Errorf("expected %s in page, got %s", first, page)
+	}
+
+	// a new version replaces the previous one
+	if err := ioutil.WriteFile(filepath.Join(dir, "static", "css", "main.css"), []byte("body { color: red; }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	second := b.assets["/css/main.css"].URL
+	if second == first {
+		t.Errorf("expected a new fingerprint, got %s", second)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out", filepath.FromSlash(second))); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out", filepath.FromSlash(first))); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed", first)
+	}
+
+	// missing assets are build errors
+	if err := ioutil.WriteFile(filepath.Join(dir, "src", "page.md"), []byte("---\nTitle: page\nTemplate: broken\n---\nPage\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Build(); err == nil || !strings.Contains(err.Error(), "asset not found: css/nosuch.css") {
+		t.Errorf("expected a missing asset error, got %v", err)
+	}
+}
+
+func TestFingerprintStatic(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := writeTree(t, map[string]string{
+		"templates/default.html":  `{{.Content}}`,
+		"static/css/main.css":     "@font-face { src: url(\"../fonts/a.woff?v=1\"); }\nbody { background: url(/img/bg.png); }\na { background: url(data:image/png;base64,AAAA); }\n",
+		"static/css/print.css":    "@import \"main.css\";\n@import url(/css/main.css) print;\n",
+		"static/fonts/a.woff":     "font",
+		"static/img/bg.png":       "png",
+		"static/img/unlisted.gif": "gif",
+		"src/page.md":             "---\nTitle: page\n---\nPage\n",
+	})
+	defer os.RemoveAll(dir)
+
+	b, err := New(Config{RootDir: dir, Src: "src", Out: "out", Template: "templates", Static: []StaticDir{{Dir: "static"}},
+		Fingerprint: []string{"css", "woff", "png"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	// all assets with a fingerprinted extension are in the manifest
+	js, err := ioutil.ReadFile(filepath.Join(dir, "out", AssetManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"/css/main.css", "/fonts/a.woff", "/img/bg.png"} {
+		a, ok := b.assets[name]
+		if !ok || a.URL == name || !strings.Contains(string(js), a.URL) {
+			t.Errorf("expected %s to be fingerprinted in the manifest %s", name, js)
+		}
+	}
+	if _, ok := b.assets["/img/unlisted.gif"]; ok {
+		t.Error("expected gif files not to be fingerprinted")
+	}
+
+	// the CSS refers to the fingerprinted copies
+	font, bg := b.assets["/fonts/a.woff"].URL, b.assets["/img/bg.png"].URL
+	for _, name := range []string{"/css/main.css", b.assets["/css/main.css"].URL} {
+		css, err := ioutil.ReadFile(filepath.Join(dir, "out", filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := "url(\"../fonts/" + filepath.Base(font) + "?v=1\")"
+		if !strings.Contains(string(css), expect) || !strings.Contains(string(css), "url("+bg+")") ||
+			!strings.Contains(string(css), "url(data:image/png;base64,AAAA)") {
+			t.Errorf("%s: unexpected CSS %s", name, css)
+		}
+	}
+
+	// and to the fingerprinted CSS files
+	main := b.assets["/css/main.css"].URL
+	css, err := ioutil.ReadFile(filepath.Join(dir, "out", filepath.FromSlash(b.assets["/css/print.css"].URL)))
+	if expect := "@import \"" + path.Base(main) + "\";\n@import url(" + main + ") print;\n"; err != nil || string(css) != expect {
+		t.Errorf("expected %q, got %q (%v)", expect, css, err)
+	}
+
+	// which cannot refer to each other
+	if err := ioutil.WriteFile(filepath.Join(dir, "static", "css", "main.css"), []byte("@import \"print.css\";\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Build(); err == nil || !strings.Contains(err.Error(), "asset reference cycle through /css/") {
+		t.Errorf("expected a reference cycle, got %v", err)
+	}
+}
@@ -0,0 +1,211 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Output sub-directory of the author pages
+const authorsDir = "authors"
+
+// Template of the author pages, no author page is generated without it
+const authorTemplate = "author"
+
+// An author of pages: a profile of the `authors` data files, or the name of
+// the `Author` key when it has no profile
+type Author struct {
+	ID     string            // key of the profile, or slug of the name, ie. juju
+	Name   string            // full name
+	Bio    string            // biography, in markdown
+	Avatar string            // URL of the avatar
+	Email  string            // email address
+	Links  map[string]string // links, by site name, ie. github
+	Pages  PAGES             // pages of the author, the newest first
+}
+
+// URL of the author page
+func (a *Author) URL() string {
+	return "/" + authorsDir + "/" + a.ID + "/"
+}
+
+// URL of the author feed
+func (a *Author) FeedURL() string {
+	return a.URL() + "rss"
+}
+
+// The author of RSS items: email (Name), or name without email
+func (a *Author) feedName() string {
+	if a.Email == "" {
+		return a.Name
+	}
+	return fmt.Sprintf("%s (%s)", a.Email, a.Name)
+}
+
+// Load the author profiles of Data["authors"], by key: each profile has the
+// keys name, bio, avatar, email and links, its ID is the slug of the key
+func (site *Site) loadAuthors() {
+	site.authors = make(map[string]*Author)
+	profiles, ok := site.Data["authors"].(map[string]interface{})
+	if !ok {
+		if _, exists := site.Data["authors"]; exists {
+			site.errorf("authors data: expected profiles by id")
+		}
+		return
+	}
+	ids := make(map[string]string)
+	for key, profile := range profiles {
+		// decoded as JSON to match the keys case-insensitively
+		a := &Author{}
+		js, err := json.Marshal(profile)
+		if err == nil {
+			err = json.Unmarshal(js, a)
+		}
+		if err != nil {
+			site.errorf("author %s: %v", key, err)
+			continue
+		}
+		// the ID is part of the URL and output directory of the author
+		a.ID = slugify(key)
+		if a.ID == "" {
+			site.errorf("author %s: invalid id", key)
+			continue
+		}
+		if other, dup := ids[a.ID]; dup {
+			site.errorf("author %s: same id %s as author %s", key, a.ID, other)
+			continue
+		}
+		ids[a.ID] = key
+		if a.Name == "" {
+			a.Name = key
+		}
+		site.authors[key] = a
+	}
+}
+
+// Return the authors of the site, by name
+func (site *Site) Authors() []*Author {
+	res := make([]*Author, 0, len(site.authors))
+	for _, a := range site.authors {
+		res = append(res, a)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// Return the author of an `Author` key: the profile of this ID or name, or
+// an author without profile, nil if empty
+func (site *Site) author(name string) *Author {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil
+	}
+	if a, ok := site.authors[name]; ok {
+		return a
+	}
+	id := slugify(name)
+	for _, a := range site.authors {
+		if strings.EqualFold(a.ID, name) || strings.EqualFold(a.Name, name) || a.ID == id {
+			return a
+		}
+	}
+	if id == "" {
+		return nil
+	}
+	a := &Author{ID: id, Name: name}
+	site.authors[id] = a
+	return a
+}
+
+// Sort the pages of the authors, from the newest
+func (site *Site) sortAuthorPages() {
+	for _, a := range site.authors {
+		a.Pages = newestFirst(a.Pages)
+	}
+}
+
+// Write the page and the feed of every author with pages, with the author
+// template. Author pages of previous builds are removed.
+func (site *Site) writeAuthorPages() {
+	b := site.b
+	dir := filepath.Join(b.PublicDir, authorsDir)
+	tpl, ok := b.templates[authorTemplate]
+	if !ok {
+		DEBUG("no %s template, author pages are not generated", authorTemplate)
+		return
+	}
+
+	written := make(map[string]bool)
+	for _, a := range site.authors {
+		if len(a.Pages) == 0 {
+			continue
+		}
+		p := site.listPage(a.URL(), a.Name, a.Bio)
+		p.Meta["RssURL"] = b.absURL(a.FeedURL())
+		p.Author = a
+		p.PubTime, p.ModTime = a.Pages[0].PubTime, a.Pages[0].PubTime
+		if a.Bio != "" {
+			if bio, err := b.markdown.Render([]byte(a.Bio)); err == nil {
+				p.Content = template.HTML(bio)
+			} else {
+				site.errorf("author %s: %v", a.ID, err)
+			}
+		}
+
+		adir := filepath.Join(dir, a.ID)
+		if err := os.MkdirAll(adir, 0755); err != nil {
+			site.errorf("author %s: %v", a.ID, err)
+			continue
+		}
+		pagers := site.paginate(a.URL(), a.Pages)
+		p.Pager = pagers[0]
+		res, err := site.execute(tpl, p)
+		if err != nil {
+			site.errorf("author %s: %v", a.ID, err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(adir, "index.html"), res, 0644); err != nil {
+			site.errorf("author %s: %v", a.ID, err)
+		}
+		site.writePagers(adir, p, tpl, pagers)
+		site.listings = append(site.listings, p)
+		title := b.Config.SiteName + " - " + a.Name
+		if err := site.writeFeed(filepath.Join(adir, "rss"), title, a.Bio, b.absURL(a.URL()), a.Pages); err != nil {
+			site.errorf("author %s: %v", a.ID, err)
+		}
+		written[a.ID] = true
+	}
+
+	// authors without pages any more, unless also a source folder
+	if _, err := os.Stat(filepath.Join(b.PostsDir, authorsDir)); err == nil {
+		return
+	}
+	files, _ := ioutil.ReadDir(dir)
+	for _, fi := range files {
+		if fi.IsDir() && !written[fi.Name()] {
+			os.RemoveAll(filepath.Join(dir, fi.Name()))
+		}
+	}
+}
+
+// Create a page not backed by a source file, ie. the page of an author, at
+// the URL path of a directory. Its meta data are the ones of the site.
+func (site *Site) listPage(urlPath, title, description string) *PAGE {
+	folder := &FOLDER{Site: site, Path: path.Clean(urlPath), Name: path.Base(urlPath)}
+	p := &PAGE{Root: site.RootFOLDER, Folder: folder, Meta: make(TemplateData)}
+	for k, v := range site.b.meta {
+		p.Meta[k] = v
+	}
+	p.Meta["Title"] = title
+	p.Meta["Description"] = description
+	return p
+}
@@ -0,0 +1,121 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuthorPages(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := writeTree(t, map[string]string{
+		"data/authors.yaml":      "juju:\n  name: Juju\n  email: juju@example.com\n  bio: Writes *things*\n  links:\n    github: https://github.com/juju\n",
+		"templates/default.html": `{{with .Author}}{{.Name}} {{.URL}}{{end}}`,
+		"templates/author.html":  `{{.Meta.Title}}|{{.Content}}|{{range .Author.Pages}}{{.Meta.Title}},{{end}}|{{.Author.Links.github}}`,
+		"src/a.md":               "---\nTitle: a\nAuthor: juju\nDate: 2019-01-01\n---\nA\n",
+		"src/b.md":               "---\nTitle: b\nAuthor: Juju\nDate: 2019-02-01\n---\nB\n",
+		"src/c.md":               "---\nTitle: c\nAuthor: Someone Else\nDate: 2019-03-01\n---\nC\n",
+	})
+	defer os.RemoveAll(dir)
+
+	b, err := New(Config{RootDir: dir, Src: "src", Out: "out", Template: "templates", SiteName: "Site", BaseURL: "http://example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authors := res.Site.Authors(); len(authors) != 2 || authors[0].ID != "juju" || authors[1].ID != "someone-else" {
+		t.Errorf("unexpected authors %v", authors)
+	}
+
+	read := func(name string) string {
+		content, err := ioutil.ReadFile(filepath.Join(dir, "out", filepath.FromSlash(name)))
+		if err != nil {
+			t.Error(err)
+		}
+		return strings.TrimSpace(string(content))
+	}
+	if got := read("c"); got != "Someone Else /authors/someone-else/" {
+		t.Errorf("unexpected page %q", got)
+	}
+	expect := "Juju|<p>Writes <em>things</em></p>\n|b,a,|https://github.com/juju"
+	if got := read("authors/juju/index.html"); got != expect {
+		t.Errorf("expected %q, got %q", expect, got)
+	}
+	feed := read("authors/juju/rss")
+	if !strings.Contains(feed, "<title>Site - Juju</title>") || !strings.Contains(feed, "<author>juju@example.com (Juju)</author>") ||
+		strings.Contains(feed, "<title>c</title>") {
+		t.Errorf("unexpected author feed %s", feed)
+	}
+	if feed := read("rss"); !strings.Contains(feed, "<author>Someone Else</author>") {
+		t.Errorf("unexpected site feed %s", feed)
+	}
+
+	// the pages of authors without pages are removed
+	if err := os.Remove(filepath.Join(dir, "src", "c.md")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out", "authors", "someone-else")); !os.IsNotExist(err) {
+		t.Error("expected the author page to be removed")
+	}
+}
+
+func TestAuthorIDs(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := writeTree(t, map[string]string{
+		"data/authors.yaml":      "../x:\n  name: X\n",
+		"templates/default.html": `{{with .Author}}{{.URL}}{{end}}`,
+		"templates/author.html":  `{{.Meta.Title}}`,
+		"src/a.md":               "---\nTitle: a\nAuthor: ../x\n---\nA\n",
+	})
+	defer os.RemoveAll(dir)
+
+	b, err := New(Config{RootDir: dir, Src: "src", Out: "out", Template: "templates", SiteName: "Site", BaseURL: "http://example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := ioutil.ReadFile(filepath.Join(dir, "out", "a")); err != nil || strings.TrimSpace(string(content)) != "/authors/x/" {
+		t.Errorf("unexpected page %q %v", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out", "authors", "x", "index.html")); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "x")); !os.IsNotExist(err) {
+		t.Error("expected no author page outside of out")
+	}
+
+	for name, profiles := range map[string]string{
+		"invalid":   "'***':\n  name: X\n",
+		"duplicate": "Jane Doe:\n  name: Jane\njane-doe:\n  name: Doe\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "data", "authors.yaml"), []byte(profiles), 0644); err != nil {
+			t.Fatal(err)
+		}
+		b, err := New(Config{RootDir: dir, Src: "src", Out: "out", Template: "templates", SiteName: "Site", BaseURL: "http://example.com"})
+		if err == nil {
+			_, err = b.Build()
+		}
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"bytes"
+
+	"github.com/russross/blackfriday"
+)
+
+// blackfriday (v1) markdown engine
+type bfMarkdown struct {
+	extensions int
+	render     blackfriday.Renderer
+}
+
+// A blackfriday option sets an extension or an HTML renderer flag
+type bfOption struct {
+	ext    int  // extension flag
+	html   int  // HTML renderer flag
+	def    bool // enabled by default
+	invert bool // the flag is set when the option is disabled
+}
+
+// blackfriday options by name, the defaults are the historical jfever ones
+var bfOptions = map[string]bfOption{
+	// extensions
+	"nointraemphasis":    {ext: blackfriday.EXTENSION_NO_INTRA_EMPHASIS, def: true},
+	"tables":             {ext: blackfriday.EXTENSION_TABLES, def: true},
+	"fencedcode":         {ext: blackfriday.EXTENSION_FENCED_CODE, def: true},
+	"autolinks":          {ext: blackfriday.EXTENSION_AUTOLINK, def: true},
+	"strikethrough":      {ext: blackfriday.EXTENSION_STRIKETHROUGH, def: true},
+	"spaceheadings":      {ext: blackfriday.EXTENSION_SPACE_HEADERS, def: true},
+	"laxhtmlblocks":      {ext: blackfriday.EXTENSION_LAX_HTML_BLOCKS},
+	"hardwraps":          {ext: blackfriday.EXTENSION_HARD_LINE_BREAK},
+	"backslashlinebreak": {ext: blackfriday.EXTENSION_BACKSLASH_LINE_BREAK},
+	"footnotes":          {ext: blackfriday.EXTENSION_FOOTNOTES},
+	"headingids":         {ext: blackfriday.EXTENSION_HEADER_IDS | blackfriday.EXTENSION_AUTO_HEADER_IDS},
+	"definitionlists":    {ext: blackfriday.EXTENSION_DEFINITION_LISTS},
+	"titleblock":         {ext: blackfriday.EXTENSION_TITLEBLOCK},
+
+	// HTML renderer
+	"xhtml":               {html: blackfriday.HTML_USE_XHTML, def: true},
+	"unsafe":              {html: blackfriday.HTML_SKIP_HTML, def: true, invert: true},
+	"safelinks":           {html: blackfriday.HTML_SAFELINK},
+	"nofollow":            {html: blackfriday.HTML_NOFOLLOW_LINKS},
+	"noreferrer":          {html: blackfriday.HTML_NOREFERRER_LINKS},
+	"targetblank":         {html: blackfriday.HTML_HREF_TARGET_BLANK},
+	"footnotereturnlinks": {html: blackfriday.HTML_FOOTNOTE_RETURN_LINKS},
+	"smartypants":         {html: blackfriday.HTML_USE_SMARTYPANTS, def: true},
+	"fractions":           {html: blackfriday.HTML_SMARTYPANTS_FRACTIONS, def: true},
+	"dashes":              {html: blackfriday.HTML_SMARTYPANTS_DASHES},
+	"latexdashes":         {html: blackfriday.HTML_SMARTYPANTS_DASHES | blackfriday.HTML_SMARTYPANTS_LATEX_DASHES},
+	"angledquotes":        {html: blackfriday.HTML_SMARTYPANTS_ANGLED_QUOTES},
+	"quotesnbsp":          {html: blackfriday.HTML_SMARTYPANTS_QUOTES_NBSP},
+}
+
+// Initialize a custom HTML render
+func newBlackfriday(opts MarkdownOptions, hl *Highlighter) (Markdown, error) {
+	known := make([]string, 0, len(bfOptions))
+	for name := range bfOptions {
+		known = append(known, name)
+	}
+	if err := opts.check("blackfriday", known...); err != nil {
+		return nil, err
+	}
+
+	bf := &bfMarkdown{}
+	htmlFlags := 0
+	for name, o := range bfOptions {
+		if opts.get(name, o.def) != o.invert {
+			bf.extensions |= o.ext
+			htmlFlags |= o.html
+		}
+	}
+	bf.render = blackfriday.HtmlRenderer(htmlFlags, "", "")
+	if hl != nil {
+		bf.render = &bfHighlighter{Renderer: bf.render, hl: hl}
+	}
+	return bf, nil
+}
+
+func (bf *bfMarkdown) Render(src []byte) ([]byte, error) {
+	return blackfriday.Markdown(src, bf.render, bf.extensions), nil
+}
+
+// HTML renderer highlighting code blocks
+type bfHighlighter struct {
+	blackfriday.Renderer
+	hl *Highlighter
+}
+
+func (r *bfHighlighter) BlockCode(out *bytes.Buffer, text []byte, info string) {
+	if out.Len() > 0 {
+		out.WriteByte('\n')
+	}
+	var buf bytes.Buffer
+	if err := r.hl.Render(&buf, text, info); err != nil {
+		WARN("cannot highlight %s code: %v", info, err)
+		lang, _ := parseFenceInfo(info)
+		writePlainCode(out, text, lang)
+		return
+	}
+	out.Write(buf.Bytes())
+}
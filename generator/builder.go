@@ -0,0 +1,251 @@
+// Package generator builds a static web site from a tree of markdown files
+// and amber templates. The jfever command is a thin wrapper around it.
+package generator
+
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/tdewolff/minify/v2"
+)
+
+// Config holds the settings of a site build, directories are either absolute
+// or relative to RootDir.
+type Config struct {
+	SiteName         string              // the name of the site
+	TagLine          string              // the site's tag line
+	RecentPostsCount int                 // the number of recent posts to send to the templates
+	FeedItems        int                 // the number of items of the feeds, default to 20
+	SummaryWords     int                 // the max number of words of the summaries, default to 70
+	PageSize         int                 // the number of pages listed by a page of the listings, default to 10
+	BaseURL          string              // the base URL of the web site
+	RootDir          string              // base of relative directories, default to the current directory
+	Src              string              // the source sub-dir name
+	Out              string              // the output sub-dir name
+	Template         string              // the template sub-dir name
+	ListTemplate     string              // the template of the folders without index page, default to list
+	URLs             string              // the URL scheme of the pages: plain (default, /page), html (/page.html) or pretty (/page/)
+	Redirects        []string            // formats of the redirect maps of the aliases written to Out: nginx, apache, netlify
+	Static           []StaticDir         // static content to be copied to Out/, later roots override earlier ones
+	Data             string              // the data files sub-dir name, default to data
+	Theme            string              // the theme of the site, if any
+	ThemesDir        string              // the directory of the themes, default to themes
+	Markdown         string              // the markdown engine and its options, ie. "goldmark, typographer=false"
+	Highlight        string              // chroma style highlighting fenced code, ie. "github", empty to disable
+	HighlightClasses bool                // highlight with CSS classes instead of inline styles
+	LineNumbers      bool                // number the lines of highlighted code
+	Fingerprint      []string            // extensions of the static assets to fingerprint, ie. css, js
+	Bundles          map[string][]string // static files concatenated in a bundle, by bundle path, ie. css/site.css
+	Minify           bool                // minify CSS, JS, SVG and the generated HTML
+	PreprocessCSS    bool                // inline the imports and replace the variables of CSS files
+	CSSVars          map[string]string   // CSS variables, overriding those of the CSS files
+	ImageWidths      []int               // widths of the srcset variants of images in Src
+	ImageMaxWidth    int                 // images in Src wider than this are resized, 0 to keep them
+	ImageQuality     int                 // JPEG quality of resized images, default to 85
+	ImageCache       string              // cache directory of processed images, default to .imagecache
+	Set              []string            // names of the settings given by the site, overriding the theme ones even when zero, default to the non-zero ones
+}
+
+// Builder generates a site from its Config, a Builder can be used for many
+// successive builds (ie. when watching changes).
+type Builder struct {
+	Config Config
+
+	RootDir       string      // Site root path, base of relative paths
+	PublicDir     string      // Public directory path
+	PostsDir      string      // Posts directory path
+	TemplatesDir  string      // Templates directory path
+	StaticDirs    []StaticDir // Static contents roots, with absolute directories
+	DataDir       string      // Data files path
+	ImageCacheDir string      // Processed images cache path
+	Themes        []*Theme    // The theme of the site and its parents, from the farthest parent
+	RssURL        string      // The RSS feed URL, parsed only once and stored for convenience
+
+	meta        TemplateData        // The site meta data can be used by posts
+	templates   map[string]Template // [templateName]=compiledTemplate
+	shortcodes  map[string]Template // [shortcodeName]=compiledTemplate
+	markdown    Markdown            // The site's markdown engine
+	highlighter *Highlighter        // Fenced code highlighter, if any
+	assets      map[string]*Asset   // Assets used by the build, by logical path
+	pending     map[string]bool     // Assets being computed, by logical path
+	bundles     map[string][]byte   // Bundles content, by logical path
+	minifier    *minify.M           // Minifier, if any
+	outputs     map[string]bool     // Files of Out written from the static roots, by logical path
+}
+
+// The result of a build
+type Result struct {
+	Site      *Site             // the generated site tree
+	Pages     int               // number of generated pages
+	Redirects map[string]string // URL of the pages, by alias
+	Errors    []error           // errors of pages which could not be (fully) generated
+}
+
+// A BuildError reports the errors of a build, the site is generated nevertheless
+type BuildError []error
+
+func (e BuildError) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%v (and %d more errors)", e[0], len(e)-1)
+}
+
+// Create a new Builder, resolving all directories
+func New(cfg Config) (*Builder, error) {
+	b := &Builder{Config: cfg}
+
+	root := cfg.RootDir
+	if root == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		root = wd
+	}
+	b.RootDir = root
+	if err := b.applyTheme(root); err != nil {
+		return nil, err
+	}
+	cfg = b.Config
+
+	// PublicDir is where the web pages are stored
+	b.PublicDir = absDir(root, cfg.Out)
+	// PostsDir is where the author's *.md are stored
+	b.PostsDir = absDir(root, cfg.Src)
+	// TemplatesDir is where templates stays
+	b.TemplatesDir = absDir(root, cfg.Template)
+	// StaticDirs is where static contents stays
+	for _, sd := range cfg.Static {
+		b.StaticDirs = append(b.StaticDirs, StaticDir{Dir: absDir(root, sd.Dir), Ignore: sd.Ignore})
+	}
+	// DataDir is where data files stays
+	if cfg.Data == "" {
+		cfg.Data = "data"
+	}
+	b.DataDir = absDir(root, cfg.Data)
+	// ImageCacheDir is where processed images are kept between builds
+	if cfg.ImageCache == "" {
+		cfg.ImageCache = ".imagecache"
+	}
+	b.ImageCacheDir = absDir(root, cfg.ImageCache)
+
+	if err := b.storeRssURL(); err != nil {
+		return nil, err
+	}
+	if err := checkURLScheme(cfg.URLs); err != nil {
+		return nil, err
+	}
+	if err := checkRedirectFormats(cfg.Redirects); err != nil {
+		return nil, err
+	}
+	if cfg.Highlight != "" {
+		hl, err := NewHighlighter(cfg.Highlight, cfg.HighlightClasses, cfg.LineNumbers)
+		if err != nil {
+			return nil, err
+		}
+		b.highlighter = hl
+	}
+	md, err := newMarkdown(cfg.Markdown, "", b.highlighter)
+	if err != nil {
+		return nil, err
+	}
+	b.markdown = md
+	if cfg.Minify {
+		b.minifier = newMinifier()
+	}
+	b.copyMeta()
+	return b, nil
+}
+
+// Return dir if absolute, or relative to root
+func absDir(root, dir string) string {
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(root, dir)
+}
+
+func (b *Builder) storeRssURL() error {
+	u, err := url.Parse(b.Config.BaseURL)
+	if err != nil {
+		return err
+	}
+	r, err := u.Parse("/rss")
+	if err != nil {
+		return err
+	}
+	b.RssURL = r.String()
+	return nil
+}
+
+func (b *Builder) copyMeta() {
+	b.meta = make(TemplateData)
+	b.meta["BaseURL"] = b.Config.BaseURL
+	b.meta["SiteName"] = b.Config.SiteName
+	b.meta["TagLine"] = b.Config.TagLine
+	b.meta["RssURL"] = b.RssURL
+}
+
+// Generate the whole site.
+func (b *Builder) Build() (*Result, error) {
+	// First compile the template(s)
+	tplDir, err := b.templatesDir()
+	if err != nil {
+		return nil, err
+	}
+	if tplDir != b.TemplatesDir {
+		defer os.RemoveAll(tplDir)
+	}
+	if err := b.compileTemplates(tplDir); err != nil {
+		DEBUG("template error %v", err)
+		return nil, err
+	}
+	if err := b.compileShortcodes(tplDir); err != nil {
+		DEBUG("shortcode template error %v", err)
+		return nil, err
+	}
+
+	b.assets = make(map[string]*Asset)
+	previous := b.readAssetManifest()
+
+	site := &Site{b: b, pages: make(map[string]*PAGE), images: make(map[string]*ImageSet), written: make(map[string]bool)}
+	site.loadData()
+	site.loadAuthors()
+
+	// copy all static assets first
+	site.copyStatic()
+	if err := b.writeBundles(); err != nil {
+		return nil, err
+	}
+
+	site.RootFOLDER = site.FOLDERTree("/")
+	if site.RootFOLDER == nil {
+		return nil, fmt.Errorf("cannot read source directory %s", b.PostsDir)
+	}
+	site.RootFOLDER.BuildTree()
+	site.BuildMap()
+	site.writeSiteFeed()
+	redirects := site.writeRedirects()
+	site.writeAuthorPages()
+	site.writeSitemap()
+	if err := b.writeAssetManifest(previous); err != nil {
+		site.errorf("%s: %v", AssetManifest, err)
+	}
+
+	res := &Result{Site: site, Pages: site.RootFOLDER.countPages(), Redirects: make(map[string]string), Errors: site.errs}
+	for _, r := range redirects {
+		res.Redirects[r.from] = r.to
+	}
+	if len(site.errs) > 0 {
+		return res, BuildError(site.errs)
+	}
+	return res, nil
+}
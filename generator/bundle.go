@@ -0,0 +1,124 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// @charset "UTF-8"; only valid at the very start of a stylesheet
+var rxCSSCharset = regexp.MustCompile(`^(?:\xEF\xBB\xBF)?@charset\s+"([^"]*)"\s*;[ \t]*\n?`)
+
+// Split the @charset and @import rules of a CSS file from the rest of it,
+// they are ignored by browsers when not at the start of a stylesheet
+func splitCSSPrelude(content []byte) (charset string, imports [][]byte, rest []byte) {
+	if m := rxCSSCharset.FindSubmatch(content); m != nil {
+		charset = string(m[1])
+		content = content[len(m[0]):]
+	}
+	rest = rxCSSImport.ReplaceAllFunc(content, func(imp []byte) []byte {
+		imports = append(imports, bytes.TrimSpace(imp))
+		return nil
+	})
+	return charset, imports, rest
+}
+
+// Concatenate the files of the configured bundles, minified if configured,
+// and write them to Out. The relative URLs of CSS files are made absolute,
+// and refer to the fingerprinted assets if configured, their @charset and
+// @import rules are moved to the start of the bundle.
+func (b *Builder) writeBundles() error {
+	b.bundles = make(map[string][]byte)
+	for name, files := range b.Config.Bundles {
+		logical := path.Clean("/" + name)
+		ext := strings.ToLower(path.Ext(logical))
+		var buf, prelude bytes.Buffer
+		charset := ""
+		for _, f := range files {
+			part := path.Clean("/" + f)
+			content, err := b.staticContent(part)
+			if err != nil {
+				return fmt.Errorf("bundle %s: %v", name, err)
+			}
+			if ext == ".css" {
+				content = absCSSURLs(content, path.Dir(part))
+				if b.rewritesCSSURLs(logical) {
+					if content, err = b.fingerprintCSSURLs(logical, content); err != nil {
+						return fmt.Errorf("bundle %s: %v", name, err)
+					}
+				}
+				cs, imports, rest := splitCSSPrelude(content)
+				if cs != "" && charset != "" && !strings.EqualFold(cs, charset) {
+					return fmt.Errorf("bundle %s: charset %s of %s differs from %s", name, cs, f, charset)
+				} else if charset == "" {
+					charset = cs
+				}
+				for _, imp := range imports {
+					prelude.Write(imp)
+					prelude.WriteString("\n")
+				}
+				content = rest
+			}
+			buf.Write(content)
+			if ext == ".js" {
+				// a missing semicolon must not join two statements
+				buf.WriteString(";")
+			}
+			if !bytes.HasSuffix(content, []byte("\n")) {
+				buf.WriteString("\n")
+			}
+		}
+		head := prelude.Bytes()
+		if charset != "" {
+			head = append([]byte(`@charset "`+charset+"\";\n"), head...)
+		}
+		content, err := b.minifyContent(logical, append(head, buf.Bytes()...))
+		if err != nil {
+			return fmt.Errorf("bundle %s: %v", name, err)
+		}
+
+		fpath := filepath.Join(b.PublicDir, filepath.FromSlash(logical))
+		if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(fpath, content, 0644); err != nil {
+			return err
+		}
+		b.bundles[logical] = content
+		b.outputs[logical] = true
+	}
+	return nil
+}
+
+// Return the HTML tag including a bundle: a stylesheet link for CSS bundles,
+// a script for JS ones
+func (b *Builder) bundleTag(name string) (template.HTML, error) {
+	logical := path.Clean("/" + name)
+	if _, ok := b.bundles[logical]; !ok {
+		return "", fmt.Errorf("unknown bundle %s", name)
+	}
+	a, err := b.asset(logical)
+	if err != nil {
+		return "", err
+	}
+	switch strings.ToLower(path.Ext(logical)) {
+	case ".css":
+		return template.HTML(fmt.Sprintf(`<link rel="stylesheet" href="%s" integrity="%s" crossorigin="anonymous">`,
+			html.EscapeString(a.URL), a.Integrity)), nil
+	case ".js":
+		return template.HTML(fmt.Sprintf(`<script src="%s" integrity="%s" crossorigin="anonymous"></script>`,
+			html.EscapeString(a.URL), a.Integrity)), nil
+	}
+	return "", fmt.Errorf("bundle %s is neither CSS nor JS", name)
+}
@@ -0,0 +1,146 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBundles(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := writeTree(t, map[string]string{
+		"templates/default.amber": "| <html>\n| <head>#{bundle(\"css/site.css\")} #{bundle(\"js/site.js\")}</head>\n| <!-- comment -->\n| <body>  #{Content}  </body></html>\n",
+		"static/css/a.css":        "/* first */\nbody {\n  margin: 0;\n}\n",
+		"static/css/b.css":        "p {\n  padding: 0;\n}",
+		"static/js/a.js":          "// first\nvar a = 1\n",
+		"static/js/b.js":          "(function() { console.log(a) })()\n",
+		"static/img/icon.svg":     "<svg xmlns=\"http://www.w3.org/2000/svg\">\n  <!-- icon -->\n  <rect width=\"1\" height=\"1\"/>\n</svg>\n",
+		"src/page.md":             "---\nTitle: page\n---\nPage\n",
+	})
+	defer os.RemoveAll(dir)
+
+	b, err := New(Config{RootDir: dir, Src: "src", Out: "out", Template: "templates", Static: []StaticDir{{Dir: "static"}}, Minify: true,
+		Bundles: map[string][]string{
+			"css/site.css": {"css/a.css", "/css/b.css"},
+			"js/site.js":   {"js/a.js", "js/b.js"},
+		}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(name string) string {
+		content, err := ioutil.ReadFile(filepath.Join(dir, "out", filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(content)
+	}
+	css := read("css/site.css")
+	if !strings.Contains(css, "margin:0") || !strings.Contains(css, "padding:0") || strings.Contains(css, "first") || strings.Contains(css, "\n") {
+		t.Errorf("unexpected CSS bundle %q", css)
+	}
+	js := read("js/site.js")
+	if !strings.Contains(js, "a=1") || !strings.Contains(js, "console.log(a)") || strings.Contains(js, "first") {
+		t.Errorf("unexpected JS bundle %q", js)
+	}
+	if svg := read("img/icon.svg"); strings.Contains(svg, "icon") {
+		t.Errorf("expected a minified SVG, got %q", svg)
+	}
+
+	page := read("page")
+	for _, tag := range []string{
+		`<link rel="stylesheet" href="/css/site.css" integrity="` + b.assets["/css/site.css"].Integrity + `" crossorigin="anonymous">`,
+		`<script src="/js/site.js" integrity="` + b.assets["/js/site.js"].Integrity + `" crossorigin="anonymous"></script>`,
+	} {
+		if !strings.Contains(page, tag) {
+			t.Errorf("expected %s in page, got %s", tag, page)
+		}
+	}
+	if strings.Contains(page, "comment") || strings.Contains(page, "  ") {
+		t.Errorf("expected a minified page, got %q", page)
+	}
+
+	// @charset and @import rules start the bundle
+	b.Config.Minify = false
+	b.minifier = nil
+	b.Config.Bundles["css/site.css"] = []string{"css/a.css", "css/c.css", "css/d.css"}
+	for name, content := range map[string]string{
+		"c.css": "@charset \"UTF-8\";\n@import url(\"https://fonts.example.com/a.css\");\nh1 { margin: 0; }\n",
+		"d.css": "@charset \"utf-8\";\n@import \"https://fonts.example.com/b.css\" print;\nh2 { margin: 0; }\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "static", "css", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	expect := "@charset \"UTF-8\";\n@import url(\"https://fonts.example.com/a.css\");\n@import \"https://fonts.example.com/b.css\" print;\n/* first */"
+	if css := read("css/site.css"); !strings.HasPrefix(css, expect) || strings.Count(css, "@") != 3 {
+		t.Errorf("unexpected CSS bundle %q", css)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "static", "css", "d.css"), []byte("@charset \"iso-8859-1\";\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Build(); err == nil || !strings.Contains(err.Error(), "charset iso-8859-1 of css/d.css differs from UTF-8") {
+		t.Errorf("expected a charset error, got %v", err)
+	}
+
+	// a missing file fails the build
+	b.Config.Bundles["css/site.css"] = append(b.Config.Bundles["css/site.css"], "css/nosuch.css")
+	if _, err := b.Build(); err == nil || !strings.Contains(err.Error(), "bundle css/site.css") {
+		t.Errorf("expected a bundle error, got %v", err)
+	}
+}
+
+func TestBundleURLs(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := writeTree(t, map[string]string{
+		"templates/default.html": `{{.Content}}`,
+		"static/css/a.css":       "@import \"print.css\" print;\n@font-face { src: url(../fonts/a.woff); }\nbody { background: url('img/bg.png'); }\n",
+		"static/css/print.css":   "body { color: black; }\n",
+		"static/css/img/bg.png":  "png",
+		"static/fonts/a.woff":    "font",
+		"static/js/a.js":         "var a = 1\n",
+		"static/js/b.js":         "(function() {})()\n",
+		"src/page.md":            "---\nTitle: page\n---\nPage\n",
+	})
+	defer os.RemoveAll(dir)
+
+	b, err := New(Config{RootDir: dir, Src: "src", Out: "out", Template: "templates", Static: []StaticDir{{Dir: "static"}},
+		Fingerprint: []string{"woff"},
+		Bundles: map[string][]string{
+			"bundles/site.css": {"css/a.css"},
+			"bundles/SITE.JS":  {"js/a.js", "js/b.js"},
+		}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the URLs are relative to the bundled files, not to the bundle
+	font := b.assets["/fonts/a.woff"].URL
+	expect := "@import \"/css/print.css\" print;\n@font-face { src: url(" + font + "); }\nbody { background: url('/css/img/bg.png'); }\n"
+	if css := string(b.bundles["/bundles/site.css"]); css != expect {
+		t.Errorf("expected CSS bundle %q, got %q", expect, css)
+	}
+	if js := string(b.bundles["/bundles/SITE.JS"]); js != "var a = 1\n;(function() {})()\n;" {
+		t.Errorf("unexpected JS bundle %q", js)
+	}
+}
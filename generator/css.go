@@ -0,0 +1,171 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// @import "a.css"; @import url(a.css) screen;
+	rxCSSImport = regexp.MustCompile(`@import\s+(?:url\(\s*)?["']?([^"')\s;]+)["']?\s*\)?\s*([^;]*);[ \t]*\n?`)
+	// url(img/a.png), url("img/a.png")
+	rxCSSURL = regexp.MustCompile(`url\(\s*(["']?)([^"')]+)(["']?)\s*\)`)
+	// @import "a.css", without url()
+	rxCSSImportString = regexp.MustCompile(`@import\s+(["'])([^"']+)(["'])`)
+	// $primary: #336699;
+	rxCSSVarDef = regexp.MustCompile(`(?m)^[ \t]*\$([A-Za-z][\w-]*)[ \t]*:[ \t]*([^;\n]*?)[ \t]*;[ \t]*\n?`)
+	rxCSSVar    = regexp.MustCompile(`\$([A-Za-z][\w-]*)`)
+	// comments, quoted strings and url() literals, other than url($var)
+	rxCSSLiteral = regexp.MustCompile(`(?s)/\*.*?\*/|"(?:[^"\\\n]|\\.)*"|'(?:[^'\\\n]|\\.)*'|url\(\s*[^\s"'$)][^)]*\)`)
+)
+
+// Tell if a static file is a CSS partial, only meant to be imported
+func isCSSPartial(name string) bool {
+	return strings.HasPrefix(path.Base(name), "_") && strings.ToLower(path.Ext(name)) == ".css"
+}
+
+// Preprocess a CSS file of the static directory: local imports are inlined,
+// variables are declared with `$name: value;` and replaced by their value.
+// CSSVars, then the site meta data, override the variables of the files.
+func (b *Builder) preprocessCSS(logical string) ([]byte, error) {
+	src, err := b.inlineCSS(logical, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := make(map[string]string)
+	src = rxCSSVarDef.ReplaceAllFunc(src, func(def []byte) []byte {
+		m := rxCSSVarDef.FindSubmatch(def)
+		vars[string(m[1])] = string(m[2])
+		return nil
+	})
+	for k, v := range b.meta {
+		vars[k] = v
+	}
+	for k, v := range b.Config.CSSVars {
+		vars[strings.TrimPrefix(k, "$")] = v
+	}
+
+	// comments, strings and urls are left as is
+	undefined := make(map[string]bool)
+	var out bytes.Buffer
+	for {
+		loc := rxCSSLiteral.FindIndex(src)
+		if loc == nil {
+			out.Write(expandCSSVars(src, vars, undefined, 0))
+			break
+		}
+		out.Write(expandCSSVars(src[:loc[0]], vars, undefined, 0))
+		out.Write(src[loc[0]:loc[1]])
+		src = src[loc[1]:]
+	}
+	if len(undefined) > 0 {
+		var names []string
+		for name := range undefined {
+			names = append(names, "$"+name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("undefined CSS variable(s) %s", strings.Join(names, ", "))
+	}
+	return out.Bytes(), nil
+}
+
+// Replace the variables of src, values can use other variables
+func expandCSSVars(src []byte, vars map[string]string, undefined map[string]bool, depth int) []byte {
+	return rxCSSVar.ReplaceAllFunc(src, func(v []byte) []byte {
+		name := string(v[1:])
+		val, ok := vars[name]
+		if !ok || depth > 10 {
+			undefined[name] = true
+			return v
+		}
+		return expandCSSVars([]byte(val), vars, undefined, depth+1)
+	})
+}
+
+// Return a CSS file of the static directory with its local imports inlined,
+// stack being the chain of files importing it
+func (b *Builder) inlineCSS(logical string, stack []string) ([]byte, error) {
+	for _, s := range stack {
+		if s == logical {
+			return nil, fmt.Errorf("CSS import cycle: %s -> %s", strings.Join(stack, " -> "), logical)
+		}
+	}
+	content, err := b.readStatic(logical)
+	if err != nil {
+		if len(stack) > 0 {
+			return nil, fmt.Errorf("%s: cannot import %s", stack[len(stack)-1], logical)
+		}
+		return nil, err
+	}
+	if len(stack) > 0 {
+		content = absCSSURLs(content, path.Dir(logical))
+	}
+
+	var ierr error
+	content = rxCSSImport.ReplaceAllFunc(content, func(imp []byte) []byte {
+		m := rxCSSImport.FindSubmatch(imp)
+		target, media := string(m[1]), strings.TrimSpace(string(m[2]))
+		if rxScheme.MatchString(target) || strings.HasPrefix(target, "//") {
+			return imp
+		}
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(logical), target)
+		}
+		inner, err := b.inlineCSS(path.Clean(target), append(stack, logical))
+		if err != nil {
+			if ierr == nil {
+				ierr = err
+			}
+			return imp
+		}
+		if !bytes.HasSuffix(inner, []byte("\n")) {
+			inner = append(inner, '\n')
+		}
+		if media != "" {
+			return []byte("@media " + media + " {\n" + string(inner) + "}\n")
+		}
+		return inner
+	})
+	return content, ierr
+}
+
+// Replace the URLs of a CSS file, of url() values and imports, by the ones
+// returned by fn, the first error of fn is returned
+func mapCSSURLs(content []byte, fn func(target string) (string, error)) ([]byte, error) {
+	var ferr error
+	for _, rx := range []*regexp.Regexp{rxCSSURL, rxCSSImportString} {
+		content = rx.ReplaceAllFunc(content, func(u []byte) []byte {
+			m := rx.FindSubmatchIndex(u)
+			target, err := fn(string(u[m[4]:m[5]]))
+			if err != nil {
+				if ferr == nil {
+					ferr = err
+				}
+				return u
+			}
+			return append(append(append([]byte{}, u[:m[4]]...), target...), u[m[5]:]...)
+		})
+	}
+	return content, ferr
+}
+
+// Make the relative URLs of a CSS file absolute, dir being the directory of
+// the file in the static directory
+func absCSSURLs(content []byte, dir string) []byte {
+	res, _ := mapCSSURLs(content, func(target string) (string, error) {
+		if path.IsAbs(target) || strings.HasPrefix(target, "#") || rxScheme.MatchString(target) || strings.HasPrefix(target, "$") {
+			return target, nil
+		}
+		return path.Join(dir, target), nil
+	})
+	return res
+}
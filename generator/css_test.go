@@ -0,0 +1,87 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPreprocessCSS(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := writeTree(t, map[string]string{
+		"static/css/main.css": `@import "_colors.css";
+@import url("print.css") print;
+@import url(https://fonts.example.com/font.css);
+/* $primary is set in _colors.css */
+body { color: $primary; background: $background; }
+a[href$=".pdf"] { border-color: $accent; }
+footer:after { content: "$price"; }
+.logo { background: url(img/$logo.png) $accent; }
+.hero { background: url($banner); }
+`,
+		"static/css/_colors.css": "$primary: #333;\n$accent: $primary;\n$background: white;\n$banner: \"banner.png\";\n@import \"/theme/_bg.css\";\n",
+		"static/css/print.css":   "body { color: black; }",
+		"static/theme/_bg.css":   "html { background: url(img/bg.png) $background; }\n",
+		"static/css/cycle.css":   "@import \"_cycle.css\";\n",
+		"static/css/_cycle.css":  "@import \"cycle.css\";\n",
+		"static/css/undef.css":   "p { color: $nosuch; margin: $nomargin; }\n",
+	})
+	defer os.RemoveAll(dir)
+
+	b, err := New(Config{RootDir: dir, Static: []StaticDir{{Dir: "static"}}, Out: "out", SiteName: "Site", PreprocessCSS: true,
+		CSSVars: map[string]string{"$primary": "#336699"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := b.preprocessCSS("/css/main.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `html { background: url(/theme/img/bg.png) white; }
+@media print {
+body { color: black; }
+}
+@import url(https://fonts.example.com/font.css);
+/* $primary is set in _colors.css */
+body { color: #336699; background: white; }
+a[href$=".pdf"] { border-color: #336699; }
+footer:after { content: "$price"; }
+.logo { background: url(img/$logo.png) #336699; }
+.hero { background: url("banner.png"); }
+`
+	if string(res) != expect {
+		t.Errorf("expected:\n%s\ngot:\n%s", expect, res)
+	}
+
+	for name, msg := range map[string]string{
+		"/css/cycle.css": "CSS import cycle: /css/cycle.css -> /css/_cycle.css -> /css/cycle.css",
+		"/css/undef.css": "undefined CSS variable(s) $nomargin, $nosuch",
+		"/css/none.css":  "file does not exist",
+	} {
+		if _, err := b.preprocessCSS(name); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("%s: expected error %q, got %v", name, msg, err)
+		}
+	}
+
+	// partials are not copied, errors are build errors
+	site := &Site{b: b}
+	site.copyStatic()
+	if content, err := ioutil.ReadFile(filepath.Join(b.PublicDir, "css", "main.css")); err != nil || string(content) != expect {
+		t.Errorf("expected a preprocessed main.css, got %s (%v)", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(b.PublicDir, "css", "_colors.css")); !os.IsNotExist(err) {
+		t.Error("expected partials not to be copied")
+	}
+	if len(site.errs) != 2 {
+		t.Errorf("expected 2 errors, got %v", site.errs)
+	}
+}
@@ -0,0 +1,137 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Decoders of the data files, by extension
+var dataDecoders = map[string]func([]byte) (interface{}, error){
+	".json": decodeJSON,
+	".yaml": decodeYAML,
+	".yml":  decodeYAML,
+	".toml": decodeTOML,
+	".csv":  decodeCSV,
+}
+
+func decodeJSON(content []byte) (interface{}, error) {
+	var res interface{}
+	err := json.Unmarshal(content, &res)
+	return res, err
+}
+
+func decodeYAML(content []byte) (interface{}, error) {
+	var res interface{}
+	err := yaml.Unmarshal(content, &res)
+	return res, err
+}
+
+func decodeTOML(content []byte) (interface{}, error) {
+	var res map[string]interface{}
+	_, err := toml.Decode(string(content), &res)
+	return res, err
+}
+
+// A CSV file is a list of records, by the names of the header row
+func decodeCSV(content []byte) (interface{}, error) {
+	rows, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	res := make([]map[string]string, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		rec := make(map[string]string, len(row))
+		for i, v := range row {
+			rec[rows[0][i]] = v
+		}
+		res = append(res, rec)
+	}
+	return res, nil
+}
+
+// Load the data files of the themes and of the site: each file is decoded to
+// the key of its path, ie. data/social/links.yaml is Data["social"]["links"].
+// The files of the site override the ones of the themes.
+func (site *Site) loadData() {
+	site.Data = make(map[string]interface{})
+	layers := make([]string, 0, len(site.b.Themes)+1)
+	for _, th := range site.b.Themes {
+		layers = append(layers, th.DataDir())
+	}
+	for _, dir := range append(layers, site.b.DataDir) {
+		err := filepath.Walk(dir, func(fpath string, fi os.FileInfo, err error) error {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			name := fi.Name()
+			if strings.HasPrefix(name, ".") && fpath != dir {
+				if fi.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			decode, ok := dataDecoders[strings.ToLower(filepath.Ext(name))]
+			if fi.IsDir() || !ok {
+				return nil
+			}
+			rel, _ := filepath.Rel(dir, fpath)
+			content, err := ioutil.ReadFile(fpath)
+			if err == nil {
+				var v interface{}
+				if v, err = decode(content); err == nil {
+					err = setData(site.Data, strings.Split(strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel)), "/"), v)
+				}
+			}
+			if err != nil {
+				site.errorf("data file %s: %v", rel, err)
+			}
+			return nil
+		})
+		if err != nil {
+			site.errorf("data directory %s: %v", dir, err)
+		}
+	}
+	DEBUG("data files loaded: %d", len(site.Data))
+}
+
+// Set the value of a key path of data, maps are merged
+func setData(data map[string]interface{}, keys []string, v interface{}) error {
+	for _, key := range keys[:len(keys)-1] {
+		sub, ok := data[key].(map[string]interface{})
+		if !ok {
+			if _, exists := data[key]; exists {
+				return fmt.Errorf("%s is both a file and a directory", key)
+			}
+			sub = make(map[string]interface{})
+			data[key] = sub
+		}
+		data = sub
+	}
+	key := keys[len(keys)-1]
+	if m, ok := v.(map[string]interface{}); ok {
+		if prev, ok := data[key].(map[string]interface{}); ok {
+			for k, mv := range m {
+				prev[k] = mv
+			}
+			return nil
+		}
+	}
+	data[key] = v
+	return nil
+}
@@ -0,0 +1,55 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDataFiles(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := writeTree(t, map[string]string{
+		"themes/base/data/site.yaml": "owner: theme\nlicense: CC-BY\n",
+		"data/site.yaml":             "owner: juju\n",
+		"data/authors/juju.toml":     "name = \"Juju\"\n[links]\nhome = \"https://example.com\"\n",
+		"data/footer.json":           `{"links": [{"title": "repos", "url": "/repos"}]}`,
+		"data/releases.csv":          "version,date\n1.0,2018-01-02\n1.1,2019-03-04\n",
+		"data/notes.txt":             "ignored",
+		"templates/default.html":     `{{with .Site.Data}}{{.site.owner}} {{.site.license}} {{.authors.juju.name}} {{range .footer.links}}{{.title}}={{.url}}{{end}} {{range .releases}}{{.version}}@{{.date}} {{end}}{{end}}`,
+		"src/page.md":                "---\nTitle: page\n---\nPage\n",
+	})
+	defer os.RemoveAll(dir)
+
+	b, err := New(Config{RootDir: dir, Src: "src", Out: "out", Template: "templates", Theme: "base"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, "out", "page"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "juju CC-BY Juju repos=/repos 1.0@2018-01-02 1.1@2019-03-04"
+	if got := strings.TrimSpace(string(content)); got != expect {
+		t.Errorf("expected %q, got %q", expect, got)
+	}
+
+	// an invalid data file is a build error
+	if err := ioutil.WriteFile(filepath.Join(dir, "data", "footer.json"), []byte(`{"links": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Build(); err == nil || !strings.Contains(err.Error(), "data file footer.json") {
+		t.Errorf("expected a data file error, got %v", err)
+	}
+}
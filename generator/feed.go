@@ -0,0 +1,77 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Default number of items of the feeds
+const DefaultFeedItems = 20
+
+// Return the pages sorted from the newest, pages published at the same time
+// are sorted by URL
+func newestFirst(pages PAGES) PAGES {
+	res := append(PAGES(nil), pages...)
+	sort.SliceStable(res, func(i, j int) bool {
+		if !res[i].PubTime.Equal(res[j].PubTime) {
+			return res[i].PubTime.After(res[j].PubTime)
+		}
+		return res[i].URL() < res[j].URL()
+	})
+	return res
+}
+
+// Write the RSS feed of the newest pages to fpath, link being the page the
+// feed is about
+func (site *Site) writeFeed(fpath, title, description, link string, pages PAGES) error {
+	b := site.b
+	rss := NewRss(title, description, link)
+	pages = newestFirst(pages)
+	n := b.Config.FeedItems
+	if n <= 0 {
+		n = DefaultFeedItems
+	}
+	if len(pages) > n {
+		pages = pages[:n]
+	}
+	for _, p := range pages {
+		author := p.Meta["Author"]
+		if p.Author != nil {
+			author = p.Author.feedName()
+		}
+		description := string(p.Summary)
+		if description == "" {
+			description = p.Meta["Description"]
+		}
+		rss.Channels[0].AppendItem(NewRssItem(p.Meta["Title"], b.absURL(p.URL()), description,
+			author, p.Meta["Category"], p.PubTime))
+	}
+	// the feed changes with its newest page only
+	if len(pages) > 0 {
+		rss.Channels[0].LastBuildDate = pages[0].PubTime.Format(time.RFC822)
+	}
+	if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+		return err
+	}
+	return rss.WriteToFile(fpath)
+}
+
+// Write the feed of the site, at RssURL
+func (site *Site) writeSiteFeed() {
+	pages := make(PAGES, 0, len(site.pages))
+	for _, p := range site.pages {
+		if p.rendered && p != p.Folder.list {
+			pages = append(pages, p)
+		}
+	}
+	b := site.b
+	if err := site.writeFeed(filepath.Join(b.PublicDir, "rss"), b.Config.SiteName, b.Config.TagLine, b.Config.BaseURL, pages); err != nil {
+		site.errorf("rss: %v", err)
+	}
+}
@@ -0,0 +1,526 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/eknkc/amber"
+)
+
+// Functions available to the templates of all engines, the ones depending
+// on the site are in builderFuncs
+var funcs = template.FuncMap{
+	// dates
+	"fmttime": func(t time.Time, f string) string {
+		return t.Format(f)
+	},
+	"fmtdate": fmtDate,
+	"now":     time.Now,
+	"adddate": func(years, months, days int, t time.Time) time.Time {
+		return t.AddDate(years, months, days)
+	},
+	"adddur": func(d string, t time.Time) (time.Time, error) {
+		dur, err := time.ParseDuration(d)
+		return t.Add(dur), err
+	},
+	"since": time.Since,
+
+	// text
+	"truncate": truncate,
+	"summary":  summary,
+	"plainify": plainify,
+	"slugify":  slugify,
+
+	// trusted content, not escaped
+	"safeHTML":     func(s string) template.HTML { return template.HTML(s) },
+	"safeHTMLAttr": func(s string) template.HTMLAttr { return template.HTMLAttr(s) },
+	"safeJS":       func(s string) template.JS { return template.JS(s) },
+	"safeCSS":      func(s string) template.CSS { return template.CSS(s) },
+	"safeURL":      func(s string) template.URL { return template.URL(s) },
+
+	// lists
+	"where":   where,
+	"sortby":  sortBy,
+	"groupby": groupBy,
+	"first":   first,
+	"dict":    dict,
+	"default": func(def, v interface{}) interface{} {
+		if isZero(v) {
+			return def
+		}
+		return v
+	},
+}
+
+func init() {
+	// Add the custom functions to Amber in the init(), since this is global
+	// (package) state in Amber. The functions bound to a builder are
+	// declared with a builder of no site, each builder replaces them.
+	for _, fm := range []template.FuncMap{funcs, new(Builder).builderFuncs()} {
+		for name, fn := range fm {
+			amber.FuncMap[name] = fn
+		}
+	}
+}
+
+// The functions of the builder's templates
+func (b *Builder) templateFuncs() template.FuncMap {
+	res := make(template.FuncMap)
+	for name, fn := range funcs {
+		res[name] = fn
+	}
+	for name, fn := range b.builderFuncs() {
+		res[name] = fn
+	}
+	return res
+}
+
+// Template functions depending on the site: URLs, markdown, data files and
+// assets
+func (b *Builder) builderFuncs() template.FuncMap {
+	res := template.FuncMap{
+		"absURL":      b.absURL,
+		"relURL":      b.relURL,
+		"markdownify": b.markdownify,
+		"getjson":     b.getJSON,
+		"getcsv":      b.getCSV,
+	}
+	for name, fn := range b.assetFuncs() {
+		res[name] = fn
+	}
+	return res
+}
+
+// Return the absolute URL of a site path, ie. /img/cat.jpg
+func (b *Builder) absURL(p string) string {
+	if u, err := url.Parse(p); err == nil && u.IsAbs() {
+		return p
+	}
+	return strings.TrimSuffix(b.Config.BaseURL, "/") + "/" + strings.TrimPrefix(p, "/")
+}
+
+// Return the URL of a site path relative to the host, with the path of
+// BaseURL if the site is not at the root of its host
+func (b *Builder) relURL(p string) string {
+	if u, err := url.Parse(p); err == nil && u.IsAbs() {
+		return p
+	}
+	base := "/"
+	if u, err := url.Parse(b.Config.BaseURL); err == nil && u.Path != "" {
+		base = u.Path
+	}
+	res := path.Join(base, p)
+	if strings.HasSuffix(p, "/") && res != "/" {
+		res += "/"
+	}
+	return res
+}
+
+// Render markdown with the site's engine, a single paragraph is unwrapped
+func (b *Builder) markdownify(s string) (template.HTML, error) {
+	res, err := b.markdown.Render([]byte(s))
+	if err != nil {
+		return "", err
+	}
+	res = bytes.TrimSpace(res)
+	if bytes.HasPrefix(res, []byte("<p>")) && bytes.HasSuffix(res, []byte("</p>")) &&
+		bytes.Count(res, []byte("<p>")) == 1 {
+		res = res[3 : len(res)-4]
+	}
+	return template.HTML(res), nil
+}
+
+// Read a file of the site, by its path relative to the site's root
+func (b *Builder) readSiteFile(name string) ([]byte, error) {
+	rel := path.Clean("/" + filepath.ToSlash(name))
+	return ioutil.ReadFile(filepath.Join(b.RootDir, filepath.FromSlash(rel)))
+}
+
+// Decode a JSON file of the site
+func (b *Builder) getJSON(name string) (interface{}, error) {
+	content, err := b.readSiteFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var res interface{}
+	if err := json.Unmarshal(content, &res); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return res, nil
+}
+
+// Decode a CSV file of the site, as records of fields
+func (b *Builder) getCSV(name string) ([][]string, error) {
+	content, err := b.readSiteFile(name)
+	if err != nil {
+		return nil, err
+	}
+	res, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return res, nil
+}
+
+// Month and day names of the date locales
+type dateLocale struct {
+	months [12]string
+	days   [7]string // from Sunday
+}
+
+// Date locales of fmtdate, by language
+var dateLocales = map[string]dateLocale{
+	"en": {
+		[12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		[7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+	},
+	"fr": {
+		[12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		[7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+	},
+	"de": {
+		[12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		[7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+	},
+	"es": {
+		[12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		[7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+	},
+	"it": {
+		[12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+		[7]string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+	},
+}
+
+var rxDateNames = regexp.MustCompile(`\b(January|February|March|April|May|June|July|August|September|October|November|December|` +
+	`Jan|Feb|Mar|Apr|Jun|Jul|Aug|Sep|Oct|Nov|Dec|` +
+	`Sunday|Monday|Tuesday|Wednesday|Thursday|Friday|Saturday|Sun|Mon|Tue|Wed|Thu|Fri|Sat)\b`)
+
+// Format a date with the month and day names of a language, ie.
+// `fmtdate "fr" "Monday 2 January 2006" .PubTime`
+func fmtDate(lang, layout string, t time.Time) (string, error) {
+	loc, ok := dateLocales[strings.ToLower(lang)]
+	if !ok {
+		return "", fmt.Errorf("unknown date language %s", lang)
+	}
+	month, day := t.Month().String(), t.Weekday().String()
+	return rxDateNames.ReplaceAllStringFunc(t.Format(layout), func(name string) string {
+		switch name {
+		case month:
+			return loc.months[t.Month()-1]
+		case month[:3]:
+			return shortName(loc.months[t.Month()-1])
+		case day:
+			return loc.days[t.Weekday()]
+		case day[:3]:
+			return shortName(loc.days[t.Weekday()])
+		}
+		return name
+	}), nil
+}
+
+// Abbreviate a month or day name to 3 letters
+func shortName(name string) string {
+	if utf8.RuneCountInString(name) <= 4 {
+		return name
+	}
+	return string([]rune(name)[:3])
+}
+
+// Truncate a text to n characters at most, cut on a word boundary
+func truncate(n int, s string) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	r := []rune(s)[:n]
+	if i := strings.LastIndexAny(string(r), " \t\n"); i > 0 {
+		return strings.TrimRight(string(r)[:i], " \t\n.,;:") + "…"
+	}
+	return string(r) + "…"
+}
+
+var (
+	rxSpaces    = regexp.MustCompile(`\s+`)
+	rxInlineTag = regexp.MustCompile(`</?(?:a|abbr|b|cite|code|del|em|i|ins|kbd|mark|q|s|small|span|strong|sub|sup|u)\b[^>]*>`)
+)
+
+// Return the text of HTML content, tags removed
+func plainify(content interface{}) string {
+	s := rxInlineTag.ReplaceAllString(fmt.Sprint(content), "")
+	s = rxTag.ReplaceAllString(s, " ")
+	return strings.TrimSpace(rxSpaces.ReplaceAllString(html.UnescapeString(s), " "))
+}
+
+// Return the first n words of the text of HTML content
+func summary(n int, content interface{}) string {
+	words := strings.Fields(plainify(content))
+	if len(words) <= n {
+		return strings.Join(words, " ")
+	}
+	return strings.Join(words[:n], " ") + "…"
+}
+
+var slugAccents = strings.NewReplacer(
+	"à", "a", "á", "a", "â", "a", "ä", "a", "ã", "a", "å", "a", "æ", "ae",
+	"ç", "c", "è", "e", "é", "e", "ê", "e", "ë", "e",
+	"ì", "i", "í", "i", "î", "i", "ï", "i", "ñ", "n",
+	"ò", "o", "ó", "o", "ô", "o", "ö", "o", "õ", "o", "ø", "o", "œ", "oe",
+	"ù", "u", "ú", "u", "û", "u", "ü", "u", "ý", "y", "ÿ", "y", "ß", "ss",
+)
+
+var rxSlugify = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Return the URL friendly form of a text, ie. "Café crème" -> "cafe-creme"
+func slugify(s string) string {
+	s = slugAccents.Replace(strings.ToLower(s))
+	return strings.Trim(rxSlugify.ReplaceAllString(s, "-"), "-")
+}
+
+// Return the value of a dotted path of fields, methods without arguments
+// and map keys, ie. "Meta.Title" or "PubTime.Year"
+func fieldValue(item interface{}, key string) (interface{}, error) {
+	v := reflect.ValueOf(item)
+	for _, name := range strings.Split(key, ".") {
+		if !v.IsValid() {
+			return nil, nil
+		}
+		if m := v.MethodByName(name); m.IsValid() && m.Type().NumIn() == 0 && m.Type().NumOut() > 0 {
+			v = m.Call(nil)[0]
+			continue
+		}
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return nil, nil
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			f := v.FieldByName(name)
+			if !f.IsValid() || !f.CanInterface() {
+				return nil, fmt.Errorf("no field %s in %s", name, v.Type())
+			}
+			v = f
+		case reflect.Map:
+			v = v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		default:
+			return nil, fmt.Errorf("no field %s in %s", name, v.Type())
+		}
+	}
+	if !v.IsValid() {
+		return nil, nil
+	}
+	return v.Interface(), nil
+}
+
+// Compare two values: dates, numbers (or numeric strings) or their text
+func compareValues(a, b interface{}) int {
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			switch {
+			case ta.Before(tb):
+				return -1
+			case ta.After(tb):
+				return 1
+			}
+			return 0
+		}
+	}
+	fa, erra := strconv.ParseFloat(fmt.Sprint(a), 64)
+	fb, errb := strconv.ParseFloat(fmt.Sprint(b), 64)
+	if erra == nil && errb == nil {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// Return the list as a slice value
+func listValue(list interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice {
+		return v, fmt.Errorf("not a list: %T", list)
+	}
+	return v, nil
+}
+
+// Return an empty list of the type of v, ie. PAGES
+func emptyList(v reflect.Value, capacity int) reflect.Value {
+	return reflect.MakeSlice(v.Type(), 0, capacity)
+}
+
+// Filter a list by a key, ie. `where .Pages "Meta.Lang" "fr"` or
+// `where .Pages "PubTime.Year" ">=" 2019`. Operators are ==, !=, <, <=, >
+// and >=.
+func where(list interface{}, key string, args ...interface{}) (interface{}, error) {
+	v, err := listValue(list)
+	if err != nil {
+		return nil, err
+	}
+	op, val := "==", interface{}(nil)
+	switch len(args) {
+	case 1:
+		val = args[0]
+	case 2:
+		op, val = fmt.Sprint(args[0]), args[1]
+	default:
+		return nil, fmt.Errorf("where: expected a value, or an operator and a value")
+	}
+
+	res := emptyList(v, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		fv, err := fieldValue(v.Index(i).Interface(), key)
+		if err != nil {
+			return nil, err
+		}
+		c := compareValues(fv, val)
+		var ok bool
+		switch op {
+		case "==", "=":
+			ok = c == 0
+		case "!=":
+			ok = c != 0
+		case "<":
+			ok = c < 0
+		case "<=":
+			ok = c <= 0
+		case ">":
+			ok = c > 0
+		case ">=":
+			ok = c >= 0
+		default:
+			return nil, fmt.Errorf("where: unknown operator %s", op)
+		}
+		if ok {
+			res = reflect.Append(res, v.Index(i))
+		}
+	}
+	return res.Interface(), nil
+}
+
+// Sort a copy of a list by a key, ie. `sortby .Pages "Meta.Title"` or
+// `sortby .Pages "PubTime" "desc"`
+func sortBy(list interface{}, key string, order ...string) (interface{}, error) {
+	v, err := listValue(list)
+	if err != nil {
+		return nil, err
+	}
+	desc := len(order) > 0 && strings.ToLower(order[0]) == "desc"
+	keys := make([]interface{}, v.Len())
+	idx := make([]int, v.Len())
+	for i := range keys {
+		if keys[i], err = fieldValue(v.Index(i).Interface(), key); err != nil {
+			return nil, err
+		}
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		c := compareValues(keys[idx[i]], keys[idx[j]])
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+	res := emptyList(v, v.Len())
+	for _, i := range idx {
+		res = reflect.Append(res, v.Index(i))
+	}
+	return res.Interface(), nil
+}
+
+// Items of a list sharing a key, as returned by groupby
+type ListGroup struct {
+	Key   string      // the key, as text
+	Items interface{} // the items, a list of the same type as the grouped one
+}
+
+// Group a list by a key, in the order of the first item of each group, ie.
+// `groupby .Pages "PubTime.Year"`
+func groupBy(list interface{}, key string) ([]ListGroup, error) {
+	v, err := listValue(list)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	groups := make(map[string]reflect.Value)
+	for i := 0; i < v.Len(); i++ {
+		fv, err := fieldValue(v.Index(i).Interface(), key)
+		if err != nil {
+			return nil, err
+		}
+		k := fmt.Sprint(fv)
+		g, ok := groups[k]
+		if !ok {
+			keys = append(keys, k)
+			g = emptyList(v, 1)
+		}
+		groups[k] = reflect.Append(g, v.Index(i))
+	}
+	res := make([]ListGroup, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, ListGroup{Key: k, Items: groups[k].Interface()})
+	}
+	return res, nil
+}
+
+// Return the first n items of a list
+func first(n int, list interface{}) (interface{}, error) {
+	v, err := listValue(list)
+	if err != nil {
+		return nil, err
+	}
+	if n < v.Len() {
+		v = v.Slice(0, n)
+	}
+	return v.Interface(), nil
+}
+
+// Build a map from key and value pairs, ie. to pass several values to a
+// partial: `{{template "partials/card.html" dict "Page" . "Size" 3}}`
+func dict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("dict: odd number of arguments")
+	}
+	res := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		res[fmt.Sprint(pairs[i])] = pairs[i+1]
+	}
+	return res, nil
+}
+
+// Tell if a value is the zero value of its type, or nil
+func isZero(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.String:
+		return rv.Len() == 0
+	}
+	return rv.IsZero()
+}
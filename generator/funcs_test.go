@@ -0,0 +1,122 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"bytes"
+	"html/template"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTextFuncs(t *testing.T) {
+	date := time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)
+	for _, c := range []struct{ lang, layout, expect string }{
+		{"fr", "Monday 2 January 2006", "mercredi 1 mai 2019"},
+		{"de", "Mon, 2 Jan 2006", "Mit, 1 Mai 2019"},
+		{"en", "Jan 2", "May 1"},
+	} {
+		if got, err := fmtDate(c.lang, c.layout, date); err != nil || got != c.expect {
+			t.Errorf("fmtdate %s %q: expected %q, got %q (%v)", c.lang, c.layout, c.expect, got, err)
+		}
+	}
+	if _, err := fmtDate("xx", "2006", date); err == nil {
+		t.Error("expected an unknown language error")
+	}
+
+	if got := truncate(12, "Je ne suis pas si certain"); got != "Je ne suis…" {
+		t.Errorf("unexpected truncate %q", got)
+	}
+	if got := summary(3, template.HTML("<p>One <em>two</em></p>\n<p>three four</p>")); got != "One two three…" {
+		t.Errorf("unexpected summary %q", got)
+	}
+	if got := slugify(" Café crème, s'il vous plaît!"); got != "cafe-creme-s-il-vous-plait" {
+		t.Errorf("unexpected slug %q", got)
+	}
+}
+
+func TestURLFuncs(t *testing.T) {
+	b := &Builder{Config: Config{BaseURL: "https://example.com/blog/"}}
+	for in, expect := range map[string]string{
+		"/img/cat.jpg":       "https://example.com/blog/img/cat.jpg",
+		"sub/":               "https://example.com/blog/sub/",
+		"http://other.com/x": "http://other.com/x",
+	} {
+		if got := b.absURL(in); got != expect {
+			t.Errorf("absURL %s: expected %s, got %s", in, expect, got)
+		}
+	}
+	for in, expect := range map[string]string{
+		"/img/cat.jpg": "/blog/img/cat.jpg",
+		"sub/":         "/blog/sub/",
+		"/":            "/blog/",
+	} {
+		if got := b.relURL(in); got != expect {
+			t.Errorf("relURL %s: expected %s, got %s", in, expect, got)
+		}
+	}
+}
+
+func TestListFuncs(t *testing.T) {
+	day := func(y, m, d int) time.Time { return time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC) }
+	pages := PAGES{
+		{PubTime: day(2018, 3, 1), Meta: TemplateData{"Title": "a", "Lang": "fr", "Weight": "10"}},
+		{PubTime: day(2019, 1, 1), Meta: TemplateData{"Title": "b", "Lang": "en", "Weight": "9"}},
+		{PubTime: day(2019, 6, 1), Meta: TemplateData{"Title": "c", "Lang": "fr", "Weight": "11"}},
+	}
+	titles := func(list interface{}) string {
+		var res []string
+		for _, p := range list.(PAGES) {
+			res = append(res, p.Meta["Title"])
+		}
+		return strings.Join(res, ",")
+	}
+
+	if res, err := where(pages, "Meta.Lang", "fr"); err != nil || titles(res) != "a,c" {
+		t.Errorf("where ==: unexpected %v (%v)", res, err)
+	}
+	if res, err := where(pages, "PubTime.Year", ">=", 2019); err != nil || titles(res) != "b,c" {
+		t.Errorf("where >=: unexpected %v (%v)", res, err)
+	}
+	if _, err := where(pages, "Nosuch", "x"); err == nil {
+		t.Error("expected an unknown field error")
+	}
+	if res, err := sortBy(pages, "Meta.Weight"); err != nil || titles(res) != "b,a,c" {
+		t.Errorf("sortby: unexpected %v (%v)", res, err)
+	}
+	if res, err := sortBy(pages, "PubTime", "desc"); err != nil || titles(res) != "c,b,a" {
+		t.Errorf("sortby desc: unexpected %v (%v)", res, err)
+	}
+	groups, err := groupBy(pages, "PubTime.Year")
+	if err != nil || len(groups) != 2 || groups[0].Key != "2018" || titles(groups[1].Items) != "b,c" {
+		t.Errorf("groupby: unexpected %v (%v)", groups, err)
+	}
+	if res, err := first(2, pages); err != nil || titles(res) != "a,b" {
+		t.Errorf("first: unexpected %v (%v)", res, err)
+	}
+}
+
+func TestFuncsInTemplates(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"data/links.json": `[{"Name": "home", "URL": "/"}]`,
+	})
+	defer os.RemoveAll(dir)
+
+	b, err := New(Config{RootDir: dir, BaseURL: "https://example.com/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := template.Must(template.New("").Funcs(b.templateFuncs()).Parse(
+		`{{markdownify "*hi*"}} {{range getjson "data/links.json"}}{{.Name}}={{absURL .URL}}{{end}} {{default "none" ""}} {{with dict "a" 1}}{{.a}}{{end}}`))
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "<em>hi</em> home=https://example.com/ none 1" {
+		t.Errorf("unexpected output %q", got)
+	}
+}
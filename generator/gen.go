@@ -0,0 +1,586 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Site structure : FOLDER
+type FOLDER struct {
+	Site     *Site
+	Path     string        // part of path relatif to SRC and OUT
+	Name     string        // navigation name
+	outfiles []os.FileInfo // (extra) files in Out
+	srcfiles []os.FileInfo // Source files
+
+	// SiteMap links
+	Subdirs []*FOLDER // subdirectories
+	Pages   PAGES     // pages in this folder
+	index   *PAGE     // index page
+	list    *PAGE     // _index.md page, content of the generated index page
+
+}
+
+// Site structure : Page
+type PAGE struct {
+	Root    *FOLDER // shortcut to root folder
+	Folder  *FOLDER // contening folder
+	SrcName string  // source .md file name
+	DstName string  // destination (slug) name
+
+	PubTime time.Time
+	ModTime time.Time
+	Prev    *PAGE
+	Next    *PAGE
+	Up      *PAGE
+
+	Meta    TemplateData
+	Author  *Author // author of the Author key, if any
+	Pager   *Pager  // page of the listing, on listing pages
+	Content template.HTML
+	TOC     []*TOCEntry // table of contents
+
+	Summary     template.HTML // content up to the more marker, or its first paragraphs
+	Truncated   bool          // the summary is not the whole content
+	WordCount   int           // number of words of the content
+	ReadingTime int           // estimated reading time, in minutes
+	buf         *bytes.Buffer
+
+	anchors  map[string]bool // ids in Content
+	rendered bool            // Content is rendered
+}
+type PAGES []*PAGE
+
+// flatten SiteMap structure: topic
+type UrlEntry struct {
+	EIndent int    // Entry indent level
+	Url     string // URL
+	Display string // Display name
+}
+
+// Site data
+type Site struct {
+	RootFOLDER *FOLDER // root FOLDER
+	SiteMap    []UrlEntry
+	Data       map[string]interface{} // content of the data files, see loadData
+
+	b        *Builder             // builder of this site
+	pages    map[string]*PAGE     // pages by source path, ie. /sub1/test.md
+	images   map[string]*ImageSet // processed images by path, ie. /img/cat.jpg
+	authors  map[string]*Author   // authors by ID
+	written  map[string]bool      // generated pages, by path in Out, ie. /sub/page.html
+	listings PAGES                // generated listings: home pages of folders without index page, author pages
+	errs     []error              // build errors
+}
+
+// Record a build error, the build goes on
+func (site *Site) errorf(format string, args ...interface{}) {
+	err := fmt.Errorf(format, args...)
+	ERROR(err.Error())
+	site.errs = append(site.errs, err)
+}
+
+// return the full Out path
+func (folder *FOLDER) GetOutDir() string {
+	return filepath.Join(folder.Site.b.PublicDir, folder.Path)
+}
+
+// return the full Src path
+func (folder *FOLDER) GetSrcDir() string {
+	return filepath.Join(folder.Site.b.PostsDir, folder.Path)
+}
+
+// Sort pages in same folder
+func (p PAGES) Less(i, j int) bool { return p[i].PubTime.Before(p[j].PubTime) }
+func (p PAGES) Len() int           { return len(p) }
+func (p PAGES) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// Compile the tempalte directory
+func (b *Builder) compileTemplates(dir string) (err error) {
+	tpls, err := b.compileDir(dir)
+	if err != nil {
+		return
+	}
+	b.templates = tpls
+	DEBUG("Directory compiled: %v", dir)
+	return nil
+}
+
+// Build a FOLDER tree from SRC directory tree
+func (site *Site) FOLDERTree(dir string) *FOLDER {
+
+	folder := FOLDER{Site: site, Path: dir, Name: filepath.Base(dir)}
+
+	files, err := ioutil.ReadDir(folder.GetSrcDir())
+	if err != nil {
+		WARN(err.Error())
+		return nil
+	}
+	// walk all files first
+	for _, fi := range files {
+		if fi.IsDir() {
+			if subfolder := site.FOLDERTree(filepath.Join(folder.Path, fi.Name())); subfolder != nil {
+				folder.Subdirs = append(folder.Subdirs, subfolder)
+			}
+		} else {
+			folder.srcfiles = append(folder.srcfiles, fi)
+		}
+	}
+
+	return &folder
+}
+
+// Build a simple navigation tree with ul/li
+func (site *Site) BuildMap() {
+	site.SiteMap = []UrlEntry{}
+	site.RootFOLDER.UrlEntries(0)
+}
+
+// apped TOC of current folder to flatten navigation TOC
+func (folder *FOLDER) UrlEntries(eident int) {
+	site := folder.Site
+
+	// add pages first
+	for _, pa := range folder.Pages {
+		site.SiteMap = append(site.SiteMap, UrlEntry{EIndent: eident, Url: pa.URL(), Display: pa.DstName})
+	}
+
+	// buil all page for current folder
+	for _, fi := range folder.Subdirs {
+		site.SiteMap = append(site.SiteMap, UrlEntry{EIndent: eident, Url: fi.Path + "/", Display: fi.Name + "/"})
+		fi.UrlEntries(eident + 1)
+	}
+
+}
+
+// Build the site from FOLDER: all pages are read, then rendered and their
+// links resolved, before being generated so that pages can link to each other
+func (folder *FOLDER) BuildTree() {
+	folder.ReadTree()
+	folder.Site.sortAuthorPages()
+	folder.RenderTree()
+	folder.ResolveTree()
+	folder.GenerateTree()
+}
+
+// Read the pages, and copy other files, of the FOLDER tree
+func (folder *FOLDER) ReadTree() {
+
+	// build all pages for current directory
+	folder.PopulateOut()
+	for _, fi := range folder.srcfiles {
+		fname := fi.Name()
+		if strings.HasPrefix(fi.Name(), ".") {
+			// ignore hidden files
+			continue
+		}
+		if fname == listIndexFile {
+			folder.newListPage()
+		} else if matched, _ := regexp.MatchString(".*\\.md", fname); matched {
+			folder.newPage(fname)
+		} else {
+			folder.copy(fname)
+		}
+	}
+
+	// read metadata for all pages of current folder
+	sort.Sort(PAGES(folder.Pages))
+
+	// read sub-directories
+	for _, fi := range folder.Subdirs {
+		fi.ReadTree()
+	}
+}
+
+// Render the markdown of all pages of the FOLDER tree
+func (folder *FOLDER) RenderTree() {
+	for _, pa := range folder.Pages {
+		folder.renderPage(pa)
+	}
+	if folder.list != nil {
+		folder.renderPage(folder.list)
+	}
+	for _, fi := range folder.Subdirs {
+		fi.RenderTree()
+	}
+}
+
+// Resolve the links of the rendered pages of the FOLDER tree, and summarize
+// them with the resolved links
+func (folder *FOLDER) ResolveTree() {
+	pages := folder.Pages
+	if folder.list != nil && folder.index == nil {
+		pages = append(pages[:len(pages):len(pages)], folder.list)
+	}
+	for _, pa := range pages {
+		if pa.rendered {
+			pa.resolveLinks()
+			pa.summarize([]byte(pa.Content))
+		}
+	}
+	for _, fi := range folder.Subdirs {
+		fi.ResolveTree()
+	}
+}
+
+// Generate the output files of the FOLDER tree
+func (folder *FOLDER) GenerateTree() {
+	// buil all page for current folder
+	for _, pa := range folder.Pages {
+		if pa.rendered {
+			folder.generateFile(pa, pa == folder.index)
+		}
+	}
+	folder.generateList()
+
+	// build sub-directories
+	for _, fi := range folder.Subdirs {
+		fi.GenerateTree()
+	}
+
+	// clean up
+	folder.CleanOut()
+}
+
+// Copy a static file in Src to Out
+func (folder *FOLDER) copy(src string) {
+	if folder.Site.b.processImages() && isImage(src) && folder.copyImage(src) {
+		return
+	}
+	fsrc := filepath.Join(folder.GetSrcDir(), src)
+	fdst := filepath.Join(folder.GetOutDir(), src)
+
+	err := copyFile(fsrc, fdst)
+	if err != nil {
+		ERROR(err.Error())
+		return
+	}
+	folder.legit(src)
+}
+
+// Copy a file from src to dst
+func copyFile(fsrc, fdst string) error {
+	inf, err := os.Open(fsrc)
+	if err != nil {
+		return err
+	}
+	defer inf.Close()
+
+	ouf, err := os.Create(fdst)
+	if err != nil {
+		return err
+	}
+	defer ouf.Close()
+
+	_, err = io.Copy(ouf, inf)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Copy a folder tree
+func copyFolder(fsrc, fdst string) error {
+	// mkdir -p
+	os.MkdirAll(fdst, 0755)
+
+	// copy file first
+	files, err := ioutil.ReadDir(fsrc)
+	if err != nil {
+		return err
+	}
+	// walk all files first
+	for _, fi := range files {
+		if !fi.IsDir() {
+			fin := fi.Name()
+			err := copyFile(filepath.Join(fsrc, fin), filepath.Join(fdst, fin))
+			if err != nil {
+				WARN(err.Error())
+			}
+		}
+	}
+
+	// walk all subfolder
+	for _, fi := range files {
+		if fi.IsDir() {
+			fin := fi.Name()
+			err := copyFolder(filepath.Join(fsrc, fin), filepath.Join(fdst, fin))
+			if err != nil {
+				WARN(err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// Mark a file in Out as legit from Src, by deleting it from outfiles
+func (folder *FOLDER) legit(src string) {
+	nf := []os.FileInfo{}
+	for _, f := range folder.outfiles {
+		if f.Name() != src {
+			nf = append(nf, f)
+		}
+	}
+	folder.outfiles = nf
+}
+
+// Cleanup: delete any extra files in Pub not present in Post
+func (folder *FOLDER) CleanOut() {
+	for _, f := range folder.outfiles {
+		logical := path.Join(folder.Path, f.Name())
+		if folder.Site.b.outputs[logical] || folder.Site.written[logical] {
+			// copied from the static roots, or a page of another folder
+			continue
+		}
+		os.Remove(filepath.Join(folder.GetOutDir(), f.Name()))
+	}
+	folder.outfiles = nil
+}
+
+// Populate pubContent from a PostsDir's sub dir
+func (folder *FOLDER) PopulateOut() {
+	outDir := folder.GetOutDir()
+
+	os.MkdirAll(outDir, 0755)
+	files, err := ioutil.ReadDir(outDir)
+	if err != nil {
+		WARN(err.Error())
+		return
+	}
+	for _, f := range files {
+		if !f.IsDir() {
+			folder.outfiles = append(folder.outfiles, f)
+		}
+	}
+}
+
+// Return the URL path of the folder, ie. /sub/
+func (folder *FOLDER) URL() string {
+	return strings.TrimSuffix(folder.Path, "/") + "/"
+}
+
+// Pages listed by the home page of the folder: its other pages, the newest
+// first
+func (folder *FOLDER) listed() PAGES {
+	var res PAGES
+	for _, p := range folder.Pages {
+		if p != folder.index && p.rendered {
+			res = append(res, p)
+		}
+	}
+	return newestFirst(res)
+}
+
+// Count generated pages of this folder and its sub-folders
+func (folder *FOLDER) countPages() int {
+	n := len(folder.Pages)
+	for _, sub := range folder.Subdirs {
+		n += sub.countPages()
+	}
+	return n
+}
+
+// create newpage, fill with metadata, but don't render template yet
+func (folder *FOLDER) newPage(mdf string) {
+	p := folder.readPage(mdf, folder.Site.b.meta)
+	if p == nil {
+		return
+	}
+	if _, ok := p.Meta["Index"]; ok {
+		folder.index = p
+	}
+	if p.Author = folder.Site.author(p.Meta["Author"]); p.Author != nil {
+		p.Author.Pages = append(p.Author.Pages, p)
+	}
+	folder.Pages = append(folder.Pages, p)
+	folder.Site.pages[p.SrcPath()] = p
+}
+
+// Read the meta data and the markdown of a page, nil on error. defaults are
+// the meta data overridden by the front matter.
+func (folder *FOLDER) readPage(mdf string, defaults TemplateData) *PAGE {
+	var p PAGE = PAGE{
+		Root:    folder.Site.RootFOLDER,
+		Folder:  folder,
+		SrcName: mdf,
+		Meta:    make(TemplateData),
+	}
+
+	fpath := filepath.Join(folder.GetSrcDir(), mdf)
+	f, err := os.Open(fpath)
+	if err != nil {
+		ERROR("Cannot open %v(%v)", fpath, err)
+		return nil
+	}
+	defer f.Close()
+
+	p.DstName = getSlug(mdf)
+	p.Meta["Slug"] = p.DstName
+
+	fi, _ := f.Stat()
+	p.PubTime = fi.ModTime()
+	p.ModTime = fi.ModTime()
+	p.Meta["PubTime"] = p.PubTime.Format("2006-01-02")
+	p.Meta["ModTime"] = p.ModTime.Format("15:04")
+
+	s := bufio.NewScanner(f)
+	meta, err := readFrontMatter(s, defaults)
+	if err != nil {
+		WARN("Cannot read meta from %v(%v)", fpath, err)
+		return nil
+	}
+	for k, v := range meta {
+		p.Meta[k] = v
+	}
+	p.DstName = p.Meta["Slug"]
+	if u := p.Meta["URL"]; u != "" {
+		if err := folder.checkURL(u); err != nil {
+			folder.Site.errorf("%s: %v", fpath, err)
+			return nil
+		}
+	}
+	if dt, ok := meta["Date"]; ok && len(dt) > 0 {
+		if pubdt, err := parseDate(dt); err == nil {
+			p.PubTime = pubdt
+			p.Meta["PubTime"] = p.PubTime.Format("2006-01-02")
+		} else {
+			WARN("Invalid date in %v(%v)", fpath, err)
+		}
+	}
+	if dt, ok := meta["PubTime"]; ok {
+		if t, err := parseDate(dt); err == nil {
+			p.PubTime = t
+		}
+	}
+
+	// Read rest of file
+	p.buf = bytes.NewBuffer(nil)
+	for s.Scan() {
+		p.buf.WriteString(s.Text() + "\n")
+	}
+	return &p
+}
+
+// return the source path of the page, relative to SRC, ie. /sub1/test.md
+func (p *PAGE) SrcPath() string {
+	return path.Join(p.Folder.Path, p.SrcName)
+}
+
+// Return the site of the page
+func (p *PAGE) Site() *Site {
+	return p.Folder.Site
+}
+
+// return the URL path of the page
+func (p *PAGE) URL() string {
+	if p.DstName == "" {
+		// home page of a folder
+		return p.Folder.URL()
+	}
+	return p.pageURL()
+}
+
+// Generate the static HTML file for the post identified by the index.
+func (folder *FOLDER) generateFile(p *PAGE, idx bool) {
+	var w io.Writer
+
+	// check if template exists
+	tplName, ok := p.Meta["Template"]
+	if !ok {
+		tplName = "default"
+	}
+	tpl, ex := folder.Site.b.templates[tplName]
+	if !ex {
+		folder.Site.errorf("%s: template not found: %s", p.SrcPath(), tplName)
+		return
+	}
+
+	out := p.outPath()
+	fpath := filepath.Join(folder.Site.b.PublicDir, filepath.FromSlash(out))
+	if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+		ERROR("error creating output %s: %s", out, err)
+		return
+	}
+	fw, err := os.Create(fpath)
+	if err != nil {
+		ERROR("error creating output %s: %s", out, err)
+		return
+	}
+	defer fw.Close()
+
+	// If this is the newest file, also save as index.html
+	w = fw
+	var pagers []*Pager
+	if idx && out != path.Join(folder.Path, "index.html") {
+		idxw, err := os.Create(filepath.Join(folder.GetOutDir(), "index.html"))
+		if err != nil {
+			ERROR("error creating static file index.html: %s", err)
+			return
+		}
+		defer idxw.Close()
+		w = io.MultiWriter(fw, idxw)
+	}
+	if idx {
+		// the home page of the folder lists its other pages
+		pagers = folder.Site.paginate(folder.URL(), folder.listed())
+		p.Pager = pagers[0]
+	}
+
+	res, err := folder.Site.execute(tpl, p)
+	if err != nil {
+		folder.Site.errorf("%s: %v", p.SrcPath(), err)
+	}
+	if _, err := w.Write(res); err != nil {
+		ERROR("error writing output %s: %s", out, err)
+	}
+	folder.Site.written[out] = true
+	if idx {
+		folder.legit("index.html")
+		folder.Site.writePagers(folder.GetOutDir(), p, tpl, pagers)
+	}
+}
+
+// Execute a template with a page, the result is minified if configured.
+// The output of a failed execution is returned with the error.
+func (site *Site) execute(tpl Template, p *PAGE) ([]byte, error) {
+	var buf bytes.Buffer
+	err := tpl.Execute(&buf, p)
+	res, merr := site.b.minifyContent("index.html", buf.Bytes())
+	if merr != nil {
+		res = buf.Bytes()
+		if err == nil {
+			err = merr
+		}
+	}
+	return res, err
+}
+
+// Render the markdown of a page to its Content and TOC
+func (folder *FOLDER) renderPage(p *PAGE) {
+	// format from mardown
+	res, err := folder.Site.b.renderMarkdown(p)
+	if err != nil {
+		folder.Site.errorf("%s: %v", p.SrcPath(), err)
+		return
+	}
+	res = p.buildTOC(res)
+	res = p.imageSrcsets(res)
+	p.Content = template.HTML(res)
+	p.anchors = collectAnchors(res)
+	p.rendered = true
+}
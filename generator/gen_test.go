@@ -0,0 +1,228 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * Copyright (c) 2013, Martin Angers
+ */
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Refresh the golden files with `go test -update`
+var update = flag.Bool("update", false, "update golden files in testdata/golden")
+
+// Modification time of the files of the fixture sites, once copied
+var fixtureModTime = time.Date(2019, 10, 4, 12, 0, 0, 0, time.UTC)
+
+func mustParse(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestSort(t *testing.T) {
+	ps := make(PAGES, 5)
+	ps[0] = &PAGE{
+		Meta:    TemplateData{"Title": "a"},
+		PubTime: mustParse("2012-01-07"),
+	}
+	ps[1] = &PAGE{
+		Meta:    TemplateData{"Title": "b"},
+		PubTime: mustParse("2012-04-22"),
+	}
+	ps[2] = &PAGE{
+		Meta:    TemplateData{"Title": "c"},
+		PubTime: mustParse("2012-01-01"),
+	}
+	ps[3] = &PAGE{
+		Meta:    TemplateData{"Title": "d"},
+		PubTime: mustParse("2011-11-30"),
+	}
+	ps[4] = &PAGE{
+		Meta:    TemplateData{"Title": "e"},
+		PubTime: mustParse("2012-12-01"),
+	}
+	sort.Sort(ps)
+
+	buf := bytes.NewBuffer(nil)
+	for _, p := range ps {
+		buf.WriteString(p.Meta["Title"])
+	}
+	if buf.String() != "dcabe" {
+		t.Errorf("expected 'dcabe', got %s", buf.String())
+	}
+}
+
+// Build every site in testdata/sites and compare the output tree with the one
+// in testdata/golden
+func TestGolden(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	sites, err := ioutil.ReadDir(filepath.Join("testdata", "sites"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range sites {
+		if !fi.IsDir() {
+			continue
+		}
+		name := fi.Name()
+		t.Run(name, func(t *testing.T) {
+			out := buildFixture(t, name)
+			defer os.RemoveAll(out)
+
+			golden := filepath.Join("testdata", "golden", name)
+			if *update {
+				if err := os.RemoveAll(golden); err != nil {
+					t.Fatal(err)
+				}
+				if err := copyFolder(out, golden); err != nil {
+					t.Fatal(err)
+				}
+			}
+			compareTrees(t, golden, out)
+		})
+	}
+}
+
+// Build the fixture site testdata/sites/<name> into a temporary directory,
+// an optional config.json in the site overrides the default Config. The site
+// is built from a copy whose files are modified at fixtureModTime.
+func buildFixture(t *testing.T, name string) string {
+	out, err := ioutil.TempDir("", "jfever")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := ioutil.TempDir("", "jfever-site")
+	if err != nil {
+		os.RemoveAll(out)
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if err := copyFolder(filepath.Join("testdata", "sites", name), root); err != nil {
+		os.RemoveAll(out)
+		t.Fatal(err)
+	}
+	err = filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(path, fixtureModTime, fixtureModTime)
+	})
+	if err != nil {
+		os.RemoveAll(out)
+		t.Fatal(err)
+	}
+	cfg := Config{
+		SiteName:         name,
+		RecentPostsCount: 5,
+		BaseURL:          "http://example.com",
+		Src:              "src",
+		Template:         "templates",
+		Static:           []StaticDir{{Dir: "static"}},
+	}
+	if js, err := ioutil.ReadFile(filepath.Join(root, "config.json")); err == nil {
+		if err := json.Unmarshal(js, &cfg); err != nil {
+			os.RemoveAll(out)
+			t.Fatal(err)
+		}
+	}
+	cache, err := ioutil.TempDir("", "jfever-images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cache)
+	cfg.RootDir, cfg.Out, cfg.ImageCache = root, out, cache
+	b, err := New(cfg)
+	if err != nil {
+		os.RemoveAll(out)
+		t.Fatal(err)
+	}
+	if _, err := b.Build(); err != nil {
+		os.RemoveAll(out)
+		t.Fatal(err)
+	}
+	return out
+}
+
+// Compare two directory trees file by file
+func compareTrees(t *testing.T, expect, got string) {
+	expFiles := listTree(t, expect)
+	gotFiles := listTree(t, got)
+	for name := range expFiles {
+		if _, ok := gotFiles[name]; !ok {
+			t.Errorf("missing file %s", name)
+		}
+	}
+	for name, content := range gotFiles {
+		exp, ok := expFiles[name]
+		if !ok {
+			t.Errorf("unexpected file %s", name)
+		} else if !bytes.Equal(exp, content) {
+			t.Errorf("%s differs:\n--- expected\n%s\n--- got\n%s", name, exp, content)
+		}
+	}
+}
+
+// Return the content of all files in a tree, by relative path
+func listTree(t *testing.T, root string) map[string][]byte {
+	files := make(map[string][]byte)
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		files[filepath.ToSlash(rel)] = b
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func BenchmarkGenerateSite(b *testing.B) {
+	b.StopTimer()
+	log.SetOutput(ioutil.Discard)
+	out, err := ioutil.TempDir("", "jfever")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(out)
+	builder, err := New(Config{
+		RecentPostsCount: 5,
+		BaseURL:          "http://localhost",
+		RootDir:          "../examples/amber",
+		Src:              "posts",
+		Out:              out,
+		Template:         "templates",
+		Static:           []StaticDir{{Dir: "static"}},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.StartTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := builder.Build()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/util"
+)
+
+// goldmark, CommonMark compliant, markdown engine
+type gmMarkdown struct {
+	md goldmark.Markdown
+}
+
+// Options known to goldmark, all but hardwraps, angledquotes and quotesnbsp
+// are enabled by default. smartypants is the same as typographer.
+var gmOptions = []string{
+	"tables", "strikethrough", "autolinks", "tasklists", "footnotes",
+	"definitionlists", "headingids", "typographer", "smartypants", "dashes",
+	"latexdashes", "angledquotes", "quotesnbsp", "xhtml", "unsafe", "hardwraps",
+}
+
+// Create a goldmark engine with GitHub flavored extensions
+func newGoldmark(opts MarkdownOptions, hl *Highlighter) (Markdown, error) {
+	if err := opts.check("goldmark", gmOptions...); err != nil {
+		return nil, err
+	}
+
+	var exts []goldmark.Extender
+	if opts.get("tables", true) {
+		exts = append(exts, extension.Table)
+	}
+	if opts.get("strikethrough", true) {
+		exts = append(exts, extension.Strikethrough)
+	}
+	if opts.get("autolinks", true) {
+		exts = append(exts, extension.Linkify)
+	}
+	if opts.get("tasklists", true) {
+		exts = append(exts, extension.TaskList)
+	}
+	if opts.get("footnotes", true) {
+		exts = append(exts, extension.Footnote)
+	}
+	if opts.get("definitionlists", true) {
+		exts = append(exts, extension.DefinitionList)
+	}
+	if opts.get("typographer", opts.get("smartypants", true)) {
+		exts = append(exts, extension.NewTypographer(
+			extension.WithTypographicSubstitutions(gmSubstitutions(opts))))
+	}
+
+	var parserOpts []parser.Option
+	if opts.get("headingids", true) {
+		parserOpts = append(parserOpts, parser.WithAutoHeadingID())
+	}
+
+	var renderOpts []renderer.Option
+	if opts.get("xhtml", true) {
+		renderOpts = append(renderOpts, html.WithXHTML())
+	}
+	if opts.get("unsafe", true) {
+		// raw HTML in markdown is kept, as with blackfriday
+		renderOpts = append(renderOpts, html.WithUnsafe())
+	}
+	if opts.get("hardwraps", false) {
+		renderOpts = append(renderOpts, html.WithHardWraps())
+	}
+	if hl != nil {
+		renderOpts = append(renderOpts, renderer.WithNodeRenderers(
+			util.Prioritized(&gmHighlighter{hl: hl}, 200)))
+	}
+
+	return &gmMarkdown{md: goldmark.New(
+		goldmark.WithExtensions(exts...),
+		goldmark.WithParserOptions(parserOpts...),
+		goldmark.WithRendererOptions(renderOpts...),
+	)}, nil
+}
+
+func (gm *gmMarkdown) Render(src []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gm.md.Convert(src, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Typographer substitutions differing from goldmark's defaults
+func gmSubstitutions(opts MarkdownOptions) map[extension.TypographicPunctuation][]byte {
+	subs := make(map[extension.TypographicPunctuation][]byte)
+	if !opts.get("dashes", true) {
+		subs[extension.EnDash] = []byte("--")
+		subs[extension.EmDash] = []byte("---")
+	} else if !opts.get("latexdashes", true) {
+		// -- is an em dash, as blackfriday's dashes
+		subs[extension.EnDash] = []byte("&mdash;")
+	}
+	if opts.get("angledquotes", false) {
+		subs[extension.LeftDoubleQuote] = []byte("&laquo;")
+		subs[extension.RightDoubleQuote] = []byte("&raquo;")
+		if opts.get("quotesnbsp", false) {
+			subs[extension.LeftDoubleQuote] = []byte("&laquo;&nbsp;")
+			subs[extension.RightDoubleQuote] = []byte("&nbsp;&raquo;")
+		}
+	}
+	return subs
+}
+
+// Node renderer highlighting fenced code blocks
+type gmHighlighter struct {
+	hl *Highlighter
+}
+
+func (r *gmHighlighter) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderFencedCode)
+}
+
+func (r *gmHighlighter) renderFencedCode(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.FencedCodeBlock)
+	var info string
+	if n.Info != nil {
+		info = string(n.Info.Segment.Value(source))
+	}
+	var code bytes.Buffer
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		code.Write(line.Value(source))
+	}
+	if err := r.hl.Render(w, code.Bytes(), info); err != nil {
+		WARN("cannot highlight %s code: %v", info, err)
+		lang, _ := parseFenceInfo(info)
+		return ast.WalkSkipChildren, writePlainCode(w, code.Bytes(), lang)
+	}
+	return ast.WalkSkipChildren, nil
+}
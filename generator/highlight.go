@@ -0,0 +1,174 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// The stylesheet written by WriteHighlightCSS, relative to the static directory
+const HighlightCSS = "css/highlight.css"
+
+// A Highlighter colours fenced code blocks at build time
+type Highlighter struct {
+	style       *chroma.Style
+	classes     bool // CSS classes instead of inline styles
+	lineNumbers bool // show line numbers by default
+}
+
+// Create a Highlighter with a chroma style name, ie. "github" or "monokai"
+func NewHighlighter(style string, classes, lineNumbers bool) (*Highlighter, error) {
+	s, ok := styles.Registry[strings.ToLower(style)]
+	if !ok {
+		return nil, fmt.Errorf("unknown highlight style %s", style)
+	}
+	return &Highlighter{style: s, classes: classes, lineNumbers: lineNumbers}, nil
+}
+
+// Fence attributes, ie. {hl_lines=[3,"5-7"], linenos=false, linenostart=10}
+var rxFenceAttr = regexp.MustCompile(`(\w+)\s*=\s*(\[[^\]]*\]|"[^"]*"|[^,\s}]+)`)
+
+// Split a fence info string in its language and attributes
+func parseFenceInfo(info string) (lang string, attrs map[string]string) {
+	attrs = make(map[string]string)
+	info = strings.TrimSpace(info)
+	if i := strings.Index(info, "{"); i >= 0 {
+		for _, m := range rxFenceAttr.FindAllStringSubmatch(info[i:], -1) {
+			v := m[2]
+			if len(v) >= 2 && (v[0] == '[' || v[0] == '"') {
+				v = v[1 : len(v)-1]
+			}
+			attrs[strings.ToLower(m[1])] = v
+		}
+		info = info[:i]
+	}
+	if fields := strings.Fields(info); len(fields) > 0 {
+		lang = fields[0]
+	}
+	return lang, attrs
+}
+
+// Parse line ranges such as `3, "5-7"` or `3 5-7`
+func parseLineRanges(s string) ([][2]int, error) {
+	var ranges [][2]int
+	for _, item := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		item = strings.Trim(item, `"`)
+		bounds := strings.SplitN(item, "-", 2)
+		from, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid line range %s", item)
+		}
+		to := from
+		if len(bounds) == 2 {
+			if to, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, fmt.Errorf("invalid line range %s", item)
+			}
+		}
+		ranges = append(ranges, [2]int{from, to})
+	}
+	return ranges, nil
+}
+
+// Render a fenced code block, info being the fence info string. Code without
+// a known language is written as is, in a plain pre/code block.
+func (hl *Highlighter) Render(w io.Writer, code []byte, info string) error {
+	lang, attrs := parseFenceInfo(info)
+	var lexer chroma.Lexer
+	if lang != "" {
+		lexer = lexers.Get(lang)
+	}
+	if lexer == nil {
+		return writePlainCode(w, code, lang)
+	}
+
+	opts := []chromahtml.Option{chromahtml.WithClasses(hl.classes)}
+	lineNumbers := hl.lineNumbers
+	if v, ok := attrs["linenos"]; ok {
+		var err error
+		if lineNumbers, err = strconv.ParseBool(v); err != nil {
+			return fmt.Errorf("invalid linenos %s", v)
+		}
+	}
+	opts = append(opts, chromahtml.WithLineNumbers(lineNumbers))
+	if v, ok := attrs["linenostart"]; ok {
+		start, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid linenostart %s", v)
+		}
+		opts = append(opts, chromahtml.BaseLineNumber(start))
+	}
+	if v, ok := attrs["hl_lines"]; ok {
+		ranges, err := parseLineRanges(v)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, chromahtml.HighlightLines(ranges))
+	}
+
+	it, err := chroma.Coalesce(lexer).Tokenise(nil, string(code))
+	if err != nil {
+		return err
+	}
+	if err := chromahtml.New(opts...).Format(w, hl.style, it); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// Write a code block without highlighting, as the markdown engines do
+func writePlainCode(w io.Writer, code []byte, lang string) error {
+	var err error
+	if lang == "" {
+		_, err = io.WriteString(w, "<pre><code>")
+	} else {
+		_, err = fmt.Fprintf(w, `<pre><code class="language-%s">`, html.EscapeString(lang))
+	}
+	if err != nil {
+		return err
+	}
+	if _, err = io.WriteString(w, html.EscapeString(string(code))); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "</code></pre>\n")
+	return err
+}
+
+// Write the stylesheet of the highlighter's style, used with CSS classes
+func (hl *Highlighter) WriteCSS(w io.Writer) error {
+	return chromahtml.New(chromahtml.WithClasses(true)).WriteCSS(w, hl.style)
+}
+
+// Write the highlighting stylesheet into the last static root, return its path
+func (b *Builder) WriteHighlightCSS() (string, error) {
+	if b.highlighter == nil {
+		return "", fmt.Errorf("highlighting is not enabled")
+	}
+	if len(b.StaticDirs) == 0 {
+		return "", fmt.Errorf("no static directory")
+	}
+	fpath := filepath.Join(b.StaticDirs[len(b.StaticDirs)-1].Dir, filepath.FromSlash(HighlightCSS))
+	if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+		return "", err
+	}
+	f, err := os.Create(fpath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return fpath, b.highlighter.WriteCSS(f)
+}
@@ -0,0 +1,117 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFenceInfo(t *testing.T) {
+	cases := []struct {
+		info  string
+		lang  string
+		attrs map[string]string
+	}{
+		{"", "", map[string]string{}},
+		{"go", "go", map[string]string{}},
+		{"go {hl_lines=[3,5]}", "go", map[string]string{"hl_lines": "3,5"}},
+		{`go {hl_lines=["2-4", 7], linenos=true}`, "go", map[string]string{"hl_lines": `"2-4", 7`, "linenos": "true"}},
+		{"{linenostart=5}", "", map[string]string{"linenostart": "5"}},
+	}
+	for _, c := range cases {
+		lang, attrs := parseFenceInfo(c.info)
+		if lang != c.lang || !reflect.DeepEqual(attrs, c.attrs) {
+			t.Errorf("%q: expected %q %v, got %q %v", c.info, c.lang, c.attrs, lang, attrs)
+		}
+	}
+}
+
+func TestParseLineRanges(t *testing.T) {
+	ranges, err := parseLineRanges(`3, "5-7" 9`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := [][2]int{{3, 3}, {5, 7}, {9, 9}}
+	if !reflect.DeepEqual(ranges, expect) {
+		t.Errorf("expected %v, got %v", expect, ranges)
+	}
+	if _, err := parseLineRanges("3-x"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestHighlighter(t *testing.T) {
+	if _, err := NewHighlighter("nosuchstyle", false, false); err == nil {
+		t.Error("expected an error for an unknown style")
+	}
+
+	hl, err := NewHighlighter("github", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := hl.Render(&buf, []byte("x := 1\n"), "go"); err != nil {
+		t.Fatal(err)
+	}
+	// inline styles
+	if !strings.Contains(buf.String(), `style="`) || strings.Contains(buf.String(), `class="kd"`) {
+		t.Errorf("expected inline styles, got %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := hl.Render(&buf, []byte("<b>\n"), "nosuchlanguage"); err != nil {
+		t.Fatal(err)
+	}
+	if expect := "<pre><code class=\"language-nosuchlanguage\">&lt;b&gt;\n</code></pre>\n"; buf.String() != expect {
+		t.Errorf("expected %q, got %q", expect, buf.String())
+	}
+
+	for _, info := range []string{"go {linenos=maybe}", "go {linenostart=x}"} {
+		if err := hl.Render(&buf, []byte("x := 1\n"), info); err == nil || !strings.Contains(err.Error(), "invalid linenos") {
+			t.Errorf("%s: expected an error, got %v", info, err)
+		}
+	}
+}
+
+func TestWriteHighlightCSS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jfever")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	b, err := New(Config{RootDir: dir, Static: []StaticDir{{Dir: "static"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.WriteHighlightCSS(); err == nil {
+		t.Error("expected an error without highlighting")
+	}
+
+	b, err = New(Config{RootDir: dir, Static: []StaticDir{{Dir: "static"}}, Highlight: "monokai", HighlightClasses: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fpath, err := b.WriteHighlightCSS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fpath != filepath.Join(dir, "static", "css", "highlight.css") {
+		t.Errorf("unexpected path %s", fpath)
+	}
+	css, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(css), ".chroma") {
+		t.Errorf("unexpected stylesheet %s", css)
+	}
+}
@@ -0,0 +1,287 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Default JPEG quality of resized images
+const DefaultImageQuality = 85
+
+// A processed image of the source tree and its srcset variants, as seen by
+// templates
+type ImageSet struct {
+	URL      string          // URL of the image, ie. /img/cat.jpg
+	Width    int             // width in pixels
+	Height   int             // height in pixels
+	Variants []*ImageVariant // smaller variants, by increasing width
+}
+
+// A resized variant of an ImageSet
+type ImageVariant struct {
+	URL    string // URL of the variant, ie. /img/cat-480w.jpg
+	Width  int
+	Height int
+}
+
+// Return the srcset attribute value of the image: its variants and itself
+func (img *ImageSet) Srcset() string {
+	var set []string
+	for _, v := range img.Variants {
+		set = append(set, fmt.Sprintf("%s %dw", v.URL, v.Width))
+	}
+	set = append(set, fmt.Sprintf("%s %dw", img.URL, img.Width))
+	return strings.Join(set, ", ")
+}
+
+// Tell if the image pipeline is enabled
+func (b *Builder) processImages() bool {
+	return len(b.Config.ImageWidths) > 0 || b.Config.ImageMaxWidth > 0
+}
+
+// Tell if a file is an image the pipeline handles
+func isImage(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	}
+	return false
+}
+
+// The cached result of processing an image: file names are relative to the
+// cache entry
+type imageEntry struct {
+	Width    int
+	Height   int
+	Variants []imageEntryVariant
+}
+
+type imageEntryVariant struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// Process an image of the folder: the image, resized to ImageMaxWidth, and
+// its variants are written to Out and recorded in the site's images.
+// Return false if the file is not a processable image, to be copied as is.
+func (folder *FOLDER) copyImage(name string) bool {
+	site := folder.Site
+	fsrc := filepath.Join(folder.GetSrcDir(), name)
+	content, err := ioutil.ReadFile(fsrc)
+	if err != nil {
+		site.errorf("%s: %v", path.Join(folder.Path, name), err)
+		return true
+	}
+
+	entry, dir, err := site.b.imageEntry(content)
+	if err != nil {
+		WARN("%s: %v, copied as is", path.Join(folder.Path, name), err)
+		return false
+	}
+
+	// write the image and its variants
+	if err := copyFile(filepath.Join(dir, "image"), filepath.Join(folder.GetOutDir(), name)); err != nil {
+		site.errorf("%s: %v", path.Join(folder.Path, name), err)
+		return true
+	}
+	folder.legit(name)
+	img := &ImageSet{URL: path.Join(folder.Path, name), Width: entry.Width, Height: entry.Height}
+	base, ext := strings.TrimSuffix(name, filepath.Ext(name)), filepath.Ext(name)
+	for _, v := range entry.Variants {
+		vname := fmt.Sprintf("%s-%dw%s", base, v.Width, ext)
+		if err := copyFile(filepath.Join(dir, v.Name), filepath.Join(folder.GetOutDir(), vname)); err != nil {
+			site.errorf("%s: %v", path.Join(folder.Path, name), err)
+			continue
+		}
+		folder.legit(vname)
+		img.Variants = append(img.Variants, &ImageVariant{URL: path.Join(folder.Path, vname), Width: v.Width, Height: v.Height})
+	}
+	site.images[img.URL] = img
+	return true
+}
+
+// Return the processed image of content, from the cache if possible, and the
+// directory holding its files
+func (b *Builder) imageEntry(content []byte) (*imageEntry, string, error) {
+	h := sha256.New()
+	h.Write(content)
+	fmt.Fprintf(h, "%v %d %d", b.Config.ImageWidths, b.Config.ImageMaxWidth, b.imageQuality())
+	key := hex.EncodeToString(h.Sum(nil))
+
+	dir := filepath.Join(b.ImageCacheDir, key[:2], key)
+	if js, err := ioutil.ReadFile(filepath.Join(dir, "image.json")); err == nil {
+		var entry imageEntry
+		if err := json.Unmarshal(js, &entry); err == nil {
+			DEBUG("image %s found in cache", key)
+			return &entry, dir, nil
+		}
+	}
+
+	entry, err := b.resizeImage(content, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, "", err
+	}
+	// the entry file is written last, an entry without it is incomplete
+	js, err := json.Marshal(entry)
+	if err != nil {
+		return nil, "", err
+	}
+	return entry, dir, ioutil.WriteFile(filepath.Join(dir, "image.json"), js, 0644)
+}
+
+// Decode an image, resize it and create its variants in dir
+func (b *Builder) resizeImage(content []byte, dir string) (*imageEntry, error) {
+	src, format, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	if format == "gif" {
+		if g, err := gif.DecodeAll(bytes.NewReader(content)); err == nil && len(g.Image) > 1 {
+			return nil, fmt.Errorf("animated GIF")
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	entry := &imageEntry{Width: bounds.Dx(), Height: bounds.Dy()}
+	if max := b.Config.ImageMaxWidth; max > 0 && entry.Width > max {
+		// too large, re-encoded at the max width
+		img := scaleImage(src, max)
+		if err := b.encodeImage(filepath.Join(dir, "image"), img, format); err != nil {
+			return nil, err
+		}
+		src = img
+		entry.Width, entry.Height = img.Bounds().Dx(), img.Bounds().Dy()
+	} else if err := ioutil.WriteFile(filepath.Join(dir, "image"), content, 0644); err != nil {
+		return nil, err
+	}
+
+	widths := append([]int(nil), b.Config.ImageWidths...)
+	sort.Ints(widths)
+	for i, w := range widths {
+		if w <= 0 || w >= entry.Width || (i > 0 && w == widths[i-1]) {
+			continue
+		}
+		img := scaleImage(src, w)
+		name := fmt.Sprintf("%dw", w)
+		if err := b.encodeImage(filepath.Join(dir, name), img, format); err != nil {
+			return nil, err
+		}
+		entry.Variants = append(entry.Variants, imageEntryVariant{Name: name, Width: w, Height: img.Bounds().Dy()})
+	}
+	return entry, nil
+}
+
+// Scale an image to width, keeping its aspect ratio
+func scaleImage(src image.Image, width int) image.Image {
+	bounds := src.Bounds()
+	height := (bounds.Dy()*width + bounds.Dx()/2) / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+	return dst
+}
+
+// Encode an image to fpath in its original format
+func (b *Builder) encodeImage(fpath string, img image.Image, format string) error {
+	f, err := os.Create(fpath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(f, img, &jpeg.Options{Quality: b.imageQuality()})
+	case "png":
+		err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(f, img)
+	case "gif":
+		err = gif.Encode(f, img, nil)
+	default:
+		err = fmt.Errorf("unsupported image format %s", format)
+	}
+	return err
+}
+
+// Return the JPEG quality of resized images
+func (b *Builder) imageQuality() int {
+	if q := b.Config.ImageQuality; q > 0 && q <= 100 {
+		return q
+	}
+	return DefaultImageQuality
+}
+
+// Return the processed image of the site at src, relative to the page or
+// absolute, nil if there is none
+func (p *PAGE) Image(src string) *ImageSet {
+	if i := strings.IndexAny(src, "?#"); i >= 0 {
+		src = src[:i]
+	}
+	if src == "" || rxScheme.MatchString(src) || strings.HasPrefix(src, "//") {
+		return nil
+	}
+	if !path.IsAbs(src) {
+		src = path.Join(p.Folder.Path, src)
+	}
+	return p.Folder.Site.images[path.Clean(src)]
+}
+
+var (
+	rxImg     = regexp.MustCompile(`<img\b[^>]*>`)
+	rxSrcset  = regexp.MustCompile(`\ssrcset\s*=`)
+	rxImgSize = regexp.MustCompile(`\s(?:width|height)\s*=`)
+)
+
+// Add the srcset, width and height of processed images to the img tags of
+// rendered content, tags with a srcset are left untouched
+func (p *PAGE) imageSrcsets(content []byte) []byte {
+	return rxImg.ReplaceAllFunc(content, func(tag []byte) []byte {
+		attrs := string(tag)
+		m := rxLinkAttr.FindStringSubmatch(attrs)
+		if m == nil || !strings.HasPrefix(strings.TrimSpace(m[0]), "src") || rxSrcset.MatchString(attrs) {
+			return tag
+		}
+		img := p.Image(html.UnescapeString(m[1] + m[2]))
+		if img == nil || len(img.Variants) == 0 {
+			return tag
+		}
+		extra := fmt.Sprintf(` srcset="%s"`, html.EscapeString(img.Srcset()))
+		if !rxImgSize.MatchString(attrs) {
+			extra += fmt.Sprintf(` width="%d" height="%d"`, img.Width, img.Height)
+		}
+		end := len(attrs) - 1
+		if strings.HasSuffix(attrs, "/>") {
+			end = len(attrs) - 2
+			for end > 0 && attrs[end-1] == ' ' {
+				end--
+			}
+		}
+		return []byte(attrs[:end] + extra + attrs[end:])
+	})
+}
@@ -0,0 +1,115 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Encode a w x h test image
+func testPNG(t *testing.T, w, h int) string {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		img.Set(x, 0, color.NRGBA{uint8(x), 0, 0, 255})
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestImageCache(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	var anim bytes.Buffer
+	frame := image.NewPaletted(image.Rect(0, 0, 40, 40), palette.Plan9)
+	if err := gif.EncodeAll(&anim, &gif.GIF{Image: []*image.Paletted{frame, frame}, Delay: []int{10, 10}}); err != nil {
+		t.Fatal(err)
+	}
+	dir := writeTree(t, map[string]string{
+		"templates/default.amber": "| #{Content}\n",
+		"src/page.md":             "![big](big.png)\n",
+		"src/big.png":             testPNG(t, 100, 50),
+		"src/anim.gif":            anim.String(),
+	})
+	defer os.RemoveAll(dir)
+
+	b, err := New(Config{RootDir: dir, Src: "src", Out: "out", Template: "templates", Static: []StaticDir{{Dir: "static"}}, ImageWidths: []int{20, 50, 200}, ImageMaxWidth: 80})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := res.Site.images["/big.png"]
+	if img == nil {
+		t.Fatal("big.png not processed")
+	}
+	if img.Width != 80 || img.Height != 40 || len(img.Variants) != 2 {
+		t.Errorf("unexpected image %+v", img)
+	}
+	if set := img.Srcset(); set != "/big-20w.png 20w, /big-50w.png 50w, /big.png 80w" {
+		t.Errorf("unexpected srcset %s", set)
+	}
+
+	// lazy loading attributes are not the ones of the image
+	p := &PAGE{Folder: res.Site.RootFOLDER}
+	for tag, expect := range map[string]string{
+		`<img data-src="big.png" src="/lazy.gif">`:   `<img data-src="big.png" src="/lazy.gif">`,
+		`<img src="big.png" data-srcset="lazy" />`:   `<img src="big.png" data-srcset="lazy" srcset="` + img.Srcset() + `" width="80" height="40" />`,
+		`<img data-width="10" src="big.png" alt="">`: `<img data-width="10" src="big.png" alt="" srcset="` + img.Srcset() + `" width="80" height="40">`,
+	} {
+		if got := string(p.imageSrcsets([]byte(tag))); got != expect {
+			t.Errorf("expected %s, got %s", expect, got)
+		}
+	}
+	if _, ok := res.Site.images["/anim.gif"]; ok {
+		t.Error("animated GIF should be copied as is")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out", "anim.gif")); err != nil {
+		t.Error(err)
+	}
+
+	// the second build uses the cache
+	entries, _ := filepath.Glob(filepath.Join(b.ImageCacheDir, "*", "*", "20w"))
+	if len(entries) != 1 {
+		t.Fatalf("expected one cache entry, got %v", entries)
+	}
+	if err := ioutil.WriteFile(entries[0], []byte("cached"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := ioutil.ReadFile(filepath.Join(dir, "out", "big-20w.png")); string(content) != "cached" {
+		t.Error("expected the cached variant")
+	}
+
+	// changing the settings invalidates the cache
+	b.Config.ImageWidths = []int{20}
+	res, err = b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := ioutil.ReadFile(filepath.Join(dir, "out", "big-20w.png")); string(content) == "cached" {
+		t.Error("expected a new variant")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out", "big-50w.png")); !os.IsNotExist(err) {
+		t.Error("expected the old variant to be cleaned")
+	}
+}
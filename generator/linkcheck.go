@@ -0,0 +1,355 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Settings of the link checker
+type LinkCheckConfig struct {
+	External    bool          // also check external URLs
+	Concurrency int           // max simultaneous external requests, default to 8
+	HostDelay   time.Duration // min delay between two requests to the same host
+	Timeout     time.Duration // timeout of an external request, default to 10s
+	CacheFile   string        // file keeping external results between runs, if any
+	CacheTTL    time.Duration // how long a cached result is trusted, default to 24h
+	Allow       []string      // external URL prefixes (or host names) never checked
+	Client      *http.Client  // HTTP client, default to one with Timeout
+}
+
+// A broken link of a page
+type BrokenLink struct {
+	URL    string // the link as found in the page
+	Reason string // why it is broken
+}
+
+// Broken links by page, pages are paths relative to the output directory
+type LinkReport map[string][]BrokenLink
+
+// Number of broken links
+func (r LinkReport) Count() int {
+	n := 0
+	for _, links := range r {
+		n += len(links)
+	}
+	return n
+}
+
+// Write the report, page by page
+func (r LinkReport) Write(w io.Writer) error {
+	pages := make([]string, 0, len(r))
+	for page := range r {
+		pages = append(pages, page)
+	}
+	sort.Strings(pages)
+	for _, page := range pages {
+		if _, err := fmt.Fprintf(w, "%s\n", page); err != nil {
+			return err
+		}
+		for _, l := range r[page] {
+			if _, err := fmt.Fprintf(w, "\t%s: %s\n", l.URL, l.Reason); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	rxLinkAttr = regexp.MustCompile(`\s(?:href|src)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	rxAnchor   = regexp.MustCompile(`\s(?:id|name)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+)
+
+// A generated HTML page
+type checkedPage struct {
+	url     string          // URL path of the page, ie. /sub/page
+	links   []string        // href and src values
+	anchors map[string]bool // id and name values
+}
+
+// Check the links of all HTML files in the output directory: internal links
+// must point to an existing file (and anchor), external ones are checked if
+// configured to.
+func (b *Builder) CheckLinks(cfg LinkCheckConfig) (LinkReport, error) {
+	pages, err := scanPages(b.PublicDir)
+	if err != nil {
+		return nil, err
+	}
+	base, err := url.Parse(b.Config.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	report := make(LinkReport)
+	external := make(map[string]map[string]bool) // URL -> pages linking to it
+	for _, p := range pages {
+		for _, lnk := range p.links {
+			u, err := url.Parse(lnk)
+			if err != nil {
+				report[p.url] = append(report[p.url], BrokenLink{lnk, "invalid URL"})
+				continue
+			}
+			if u.Scheme == "" && u.Host != "" {
+				// protocol relative URL
+				u.Scheme = base.Scheme
+			}
+			switch {
+			case u.Scheme == "http" || u.Scheme == "https":
+				if u.Host != base.Host {
+					u.Fragment = ""
+					if external[u.String()] == nil {
+						external[u.String()] = make(map[string]bool)
+					}
+					external[u.String()][p.url] = true
+					continue
+				}
+			case u.Scheme != "" || (u.Path == "" && u.Fragment == ""):
+				// mailto:, data:... or query only
+				continue
+			}
+			if reason := checkInternal(b.PublicDir, pages, p, u); reason != "" {
+				report[p.url] = append(report[p.url], BrokenLink{lnk, reason})
+			}
+		}
+	}
+
+	if cfg.External {
+		results, err := checkExternal(external, cfg)
+		if err != nil {
+			return nil, err
+		}
+		for u, reason := range results {
+			for page := range external[u] {
+				report[page] = append(report[page], BrokenLink{u, reason})
+			}
+		}
+	}
+	for _, links := range report {
+		sort.Slice(links, func(i, j int) bool { return links[i].URL < links[j].URL })
+	}
+	return report, nil
+}
+
+// Read all HTML files of dir: *.html, *.htm and extension-less pages
+func scanPages(dir string) (map[string]*checkedPage, error) {
+	pages := make(map[string]*checkedPage)
+	err := filepath.Walk(dir, func(fpath string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(fpath))
+		if ext != "" && ext != ".html" && ext != ".htm" {
+			return nil
+		}
+		content, err := ioutil.ReadFile(fpath)
+		if err != nil {
+			return err
+		}
+		if ext == "" && !strings.HasPrefix(http.DetectContentType(content), "text/html") {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, fpath)
+		p := &checkedPage{url: "/" + filepath.ToSlash(rel), anchors: make(map[string]bool)}
+		for _, m := range rxLinkAttr.FindAllSubmatch(content, -1) {
+			p.links = append(p.links, html.UnescapeString(string(m[1])+string(m[2])))
+		}
+		for _, m := range rxAnchor.FindAllSubmatch(content, -1) {
+			p.anchors[html.UnescapeString(string(m[1])+string(m[2]))] = true
+		}
+		pages[p.url] = p
+		return nil
+	})
+	return pages, err
+}
+
+// Check an internal link of page p, return why it is broken or ""
+func checkInternal(dir string, pages map[string]*checkedPage, p *checkedPage, u *url.URL) string {
+	target := p.url
+	if u.Path != "" {
+		target = u.Path
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(p.url), target)
+		}
+		target = path.Clean(target)
+	}
+
+	fi, err := os.Stat(filepath.Join(dir, filepath.FromSlash(target)))
+	if err != nil {
+		return "not found"
+	}
+	if fi.IsDir() {
+		target = path.Join(target, "index.html")
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(target))); err != nil {
+			return "directory without index.html"
+		}
+	}
+	if u.Fragment != "" {
+		if tp, ok := pages[target]; ok && !tp.anchors[u.Fragment] {
+			return "missing anchor #" + u.Fragment
+		}
+	}
+	return ""
+}
+
+// A cached external check
+type linkResult struct {
+	Reason  string    // why the link is broken, empty if not
+	Checked time.Time // when it was checked
+}
+
+// Check external URLs, return the broken ones with the reason
+func checkExternal(urls map[string]map[string]bool, cfg LinkCheckConfig) (map[string]string, error) {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 8
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = 24 * time.Hour
+	}
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: cfg.Timeout}
+	}
+
+	cache := make(map[string]linkResult)
+	if cfg.CacheFile != "" {
+		if js, err := ioutil.ReadFile(cfg.CacheFile); err == nil {
+			if err := json.Unmarshal(js, &cache); err != nil {
+				WARN("ignoring invalid link cache %s: %v", cfg.CacheFile, err)
+			}
+		}
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, cfg.Concurrency)
+		limiter = newHostLimiter(cfg.HostDelay)
+		broken  = make(map[string]string)
+		now     = time.Now()
+	)
+	for u := range urls {
+		if linkAllowed(u, cfg.Allow) {
+			continue
+		}
+		if r, ok := cache[u]; ok && now.Sub(r.Checked) < cfg.CacheTTL {
+			if r.Reason != "" {
+				broken[u] = r.Reason
+			}
+			continue
+		}
+		wg.Add(1)
+		go func(u string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			reason := checkURL(client, limiter, u)
+			mu.Lock()
+			defer mu.Unlock()
+			cache[u] = linkResult{Reason: reason, Checked: time.Now()}
+			if reason != "" {
+				broken[u] = reason
+			}
+		}(u)
+	}
+	wg.Wait()
+
+	if cfg.CacheFile != "" {
+		js, err := json.MarshalIndent(cache, "", "\t")
+		if err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(cfg.CacheFile, js, 0644); err != nil {
+			return nil, err
+		}
+	}
+	return broken, nil
+}
+
+// Tell if an URL is in the allow list, by prefix or host name
+func linkAllowed(u string, allow []string) bool {
+	host := ""
+	if pu, err := url.Parse(u); err == nil {
+		host = pu.Hostname()
+	}
+	for _, a := range allow {
+		if strings.HasPrefix(u, a) || a == host {
+			return true
+		}
+	}
+	return false
+}
+
+// Request an URL, with HEAD first and GET for servers not supporting it,
+// return why it is broken or ""
+func checkURL(client *http.Client, limiter *hostLimiter, u string) string {
+	var status int
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequest(method, u, nil)
+		if err != nil {
+			return err.Error()
+		}
+		limiter.wait(req.URL.Host)
+		resp, err := client.Do(req)
+		if err != nil {
+			return err.Error()
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		status = resp.StatusCode
+		if status != http.StatusMethodNotAllowed && status != http.StatusNotImplemented && status != http.StatusForbidden {
+			break
+		}
+	}
+	if status >= 400 {
+		return fmt.Sprintf("%d %s", status, http.StatusText(status))
+	}
+	return ""
+}
+
+// Spaces requests to the same host by a minimal delay
+type hostLimiter struct {
+	delay time.Duration
+	mu    sync.Mutex
+	next  map[string]time.Time // next time a request to the host is allowed
+}
+
+func newHostLimiter(delay time.Duration) *hostLimiter {
+	return &hostLimiter{delay: delay, next: make(map[string]time.Time)}
+}
+
+// Wait for the turn of a request to host
+func (l *hostLimiter) wait(host string) {
+	if l.delay <= 0 {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[host] = at.Add(l.delay)
+	l.mu.Unlock()
+	time.Sleep(at.Sub(now))
+}
@@ -0,0 +1,127 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckLinks(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/ok":
+		case "/nohead":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		case "/allowed":
+			t.Errorf("allowed URL %s checked", r.URL)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	dir := writeTree(t, map[string]string{
+		"out/index.html": `<!DOCTYPE html><html><body id="top">
+<a href="/page">page</a> <a href="page#part">part</a> <a href="#top">top</a>
+<a href="sub/">sub</a> <a href="/css/main.css">css</a> <img src="/nosuch.png">
+<a href="page#nosuch">bad anchor</a> <a href="nodir/">no index</a> <a href="mailto:me@example.com">me</a>
+<a href="http://example.com/page">own absolute link</a>
+<a href="` + srv.URL + `/ok">ok</a> <a href="` + srv.URL + `/missing#x">missing</a>
+<a href="` + srv.URL + `/nohead">nohead</a> <a href="` + srv.URL + `/allowed">allowed</a>
+</body></html>`,
+		"out/page":           `<!DOCTYPE html><html><h2 id="part">Part</h2><p data-id="nosuch" data-href="/nosuch">p</p><a href="` + srv.URL + `/ok">ok</a></html>`,
+		"out/sub/index.html": `<html><a href="../page">up</a></html>`,
+		"out/nodir/x.txt":    "not a page",
+		"out/css/main.css":   `body { background: url("/nosuch.png") }`,
+	})
+	defer os.RemoveAll(dir)
+
+	b, err := New(Config{RootDir: dir, Out: "out", BaseURL: "http://example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// internal links only
+	report, err := b.CheckLinks(LinkCheckConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	report.Write(&buf)
+	expect := `/index.html
+	/nosuch.png: not found
+	nodir/: directory without index.html
+	page#nosuch: missing anchor #nosuch
+`
+	if buf.String() != expect {
+		t.Errorf("expected report:\n%s\ngot:\n%s", expect, buf.String())
+	}
+	if requests != 0 {
+		t.Errorf("expected no external request, got %d", requests)
+	}
+
+	// with external links, cached
+	cfg := LinkCheckConfig{
+		External:    true,
+		Concurrency: 2,
+		HostDelay:   10 * time.Millisecond,
+		CacheFile:   filepath.Join(dir, "links.json"),
+		Allow:       []string{srv.URL + "/allowed"},
+	}
+	report, err = b.CheckLinks(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := report.Count(); n != 4 {
+		t.Errorf("expected 4 broken links, got %d", n)
+	}
+	var missing *BrokenLink
+	for i, l := range report["/index.html"] {
+		if l.URL == srv.URL+"/missing" {
+			missing = &report["/index.html"][i]
+		}
+	}
+	if missing == nil || missing.Reason != "404 Not Found" {
+		t.Errorf("expected %s/missing to be reported as 404, got %v", srv.URL, report["/index.html"])
+	}
+	// HEAD and GET for nohead, HEAD for ok and missing
+	if requests != 4 {
+		t.Errorf("expected 4 requests, got %d", requests)
+	}
+
+	report, err = b.CheckLinks(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := report.Count(); n != 4 || requests != 4 {
+		t.Errorf("expected 4 cached broken links and no request, got %d and %d requests", n, requests)
+	}
+}
+
+func TestHostLimiter(t *testing.T) {
+	l := newHostLimiter(20 * time.Millisecond)
+	start := time.Now()
+	l.wait("a")
+	l.wait("b")
+	if time.Since(start) > 15*time.Millisecond {
+		t.Error("different hosts should not wait")
+	}
+	l.wait("a")
+	l.wait("a")
+	if time.Since(start) < 40*time.Millisecond {
+		t.Error("expected requests to the same host to be spaced")
+	}
+}
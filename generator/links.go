@@ -0,0 +1,112 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	rxHref   = regexp.MustCompile(`\bhref="([^"]*)"`)
+	rxScheme = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+)
+
+// Return all ids of rendered content, the anchors links can point to
+func collectAnchors(content []byte) map[string]bool {
+	anchors := make(map[string]bool)
+	for _, m := range rxID.FindAllSubmatch(content, -1) {
+		anchors[html.UnescapeString(string(m[1]))] = true
+	}
+	return anchors
+}
+
+// Rewrite links to source .md files to their page URL, unresolved links and
+// missing anchors are build errors. Other relative links and sources are made
+// absolute when the page is not written to the directory of its folder.
+func (p *PAGE) resolveLinks() {
+	p.Content = template.HTML(rxHref.ReplaceAllStringFunc(string(p.Content), func(attr string) string {
+		href := html.UnescapeString(rxHref.FindStringSubmatch(attr)[1])
+		target, err := p.resolveLink(href)
+		if err != nil {
+			p.Folder.Site.errorf("%s: %v", p.SrcPath(), err)
+			return attr
+		}
+		if target == href {
+			return attr
+		}
+		return `href="` + html.EscapeString(target) + `"`
+	}))
+	if p.baseURL() == p.Folder.URL() {
+		return
+	}
+	p.Content = template.HTML(rxLinkAttr.ReplaceAllStringFunc(string(p.Content), func(attr string) string {
+		m := rxLinkAttr.FindStringSubmatchIndex(attr)
+		start, end := m[2], m[3]
+		if start < 0 {
+			start, end = m[4], m[5]
+		}
+		lnk := html.UnescapeString(attr[start:end])
+		if lnk == "" || strings.HasPrefix(lnk, "/") || strings.HasPrefix(lnk, "#") || strings.HasPrefix(lnk, "?") ||
+			rxScheme.MatchString(lnk) {
+			return attr
+		}
+		rest := ""
+		if i := strings.IndexAny(lnk, "?#"); i >= 0 {
+			lnk, rest = lnk[:i], lnk[i:]
+		}
+		return attr[:start] + html.EscapeString(p.Folder.resolveURL(lnk)+rest) + attr[end:]
+	}))
+}
+
+// Resolve a link of the page, return the link itself if it does not point
+// to a source file
+func (p *PAGE) resolveLink(href string) (string, error) {
+	if href == "" || rxScheme.MatchString(href) || strings.HasPrefix(href, "//") {
+		return href, nil
+	}
+	lnk, frag := href, ""
+	if i := strings.Index(href, "#"); i >= 0 {
+		lnk, frag = href[:i], href[i+1:]
+	}
+
+	// anchor in the page itself
+	if lnk == "" {
+		if frag != "" && !p.anchors[frag] {
+			return href, fmt.Errorf("missing anchor #%s", frag)
+		}
+		return href, nil
+	}
+
+	if i := strings.Index(lnk, "?"); i >= 0 {
+		lnk = lnk[:i]
+	}
+	if path.Ext(lnk) != ".md" {
+		return href, nil
+	}
+	src, err := url.PathUnescape(lnk)
+	if err != nil {
+		return href, fmt.Errorf("invalid link %s", href)
+	}
+	if !path.IsAbs(src) {
+		src = path.Join(p.Folder.Path, src)
+	}
+	target, ok := p.Folder.Site.pages[path.Clean(src)]
+	if !ok {
+		return href, fmt.Errorf("unresolved link %s", href)
+	}
+	if frag == "" {
+		return target.URL(), nil
+	}
+	if target.rendered && !target.anchors[frag] {
+		return href, fmt.Errorf("missing anchor #%s in %s", frag, target.SrcPath())
+	}
+	return target.URL() + "#" + frag, nil
+}
@@ -0,0 +1,77 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Write files, by relative path, in a new temporary directory
+func writeTree(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "jfever")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		fpath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fpath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestBrokenLinks(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := writeTree(t, map[string]string{
+		"templates/default.amber": "| #{Content}\n",
+		"src/a.md":                "---\nTitle: a\n---\n# A\n[ok](sub/b.md#b) [missing](nosuch.md) [anchor](sub/b.md#nosuch)\n",
+		"src/sub/b.md":            "---\nTitle: b\n---\n# B\n[ok](../a.md) [self](#nosuch)\n",
+	})
+	defer os.RemoveAll(dir)
+
+	b, err := New(Config{RootDir: dir, Src: "src", Out: "out", Template: "templates", Static: []StaticDir{{Dir: "static"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := b.Build()
+	if _, ok := err.(BuildError); !ok {
+		t.Fatalf("expected a BuildError, got %v", err)
+	}
+
+	var errs []string
+	for _, e := range res.Errors {
+		errs = append(errs, e.Error())
+	}
+	sort.Strings(errs)
+	expect := []string{
+		"/a.md: missing anchor #nosuch in /sub/b.md",
+		"/a.md: unresolved link nosuch.md",
+		"/sub/b.md: missing anchor #nosuch",
+	}
+	if strings.Join(errs, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("expected errors:\n%s\ngot:\n%s", strings.Join(expect, "\n"), strings.Join(errs, "\n"))
+	}
+
+	// pages are generated nevertheless, with the resolved links rewritten
+	out, err := ioutil.ReadFile(filepath.Join(dir, "out", "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `<a href="/sub/b#b">ok</a>`) {
+		t.Errorf("expected a resolved link in %s", out)
+	}
+}
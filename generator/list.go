@@ -0,0 +1,107 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"io/ioutil"
+	"path/filepath"
+)
+
+// Default template of the folders without index page
+const DefaultListTemplate = "list"
+
+// Source file giving its content and meta data to the generated index page
+// of a folder
+const listIndexFile = "_index.md"
+
+// Return the name of the template of the folders without index page
+func (b *Builder) listTemplate() string {
+	if b.Config.ListTemplate == "" {
+		return DefaultListTemplate
+	}
+	return b.Config.ListTemplate
+}
+
+// Read the _index.md page of the folder, its URL is the one of the folder
+func (folder *FOLDER) newListPage() {
+	defaults := make(TemplateData)
+	for k, v := range folder.Site.b.meta {
+		defaults[k] = v
+	}
+	defaults["Template"] = folder.Site.b.listTemplate()
+	p := folder.readPage(listIndexFile, defaults)
+	if p == nil {
+		return
+	}
+	p.DstName = ""
+	folder.list = p
+	folder.Site.pages[p.SrcPath()] = p
+}
+
+// Return the title of the folder: the one of its index page, or its name
+func (folder *FOLDER) Title() string {
+	for _, p := range []*PAGE{folder.index, folder.list} {
+		if p != nil && p.Meta["Title"] != "" {
+			return p.Meta["Title"]
+		}
+	}
+	if folder.Path == "/" {
+		return folder.Site.b.Config.SiteName
+	}
+	return folder.Name
+}
+
+// Generate the index page of a folder without page with the Index key from
+// the list template, listing its pages and sub-folders. Its content and meta
+// data come from _index.md if any, the list is not generated without it if
+// there is no list template.
+func (folder *FOLDER) generateList() {
+	site := folder.Site
+	p := folder.list
+	if folder.index != nil {
+		if p != nil {
+			WARN("%s ignored, %s is the index page", p.SrcPath(), folder.index.SrcPath())
+		}
+		return
+	}
+
+	if p == nil {
+		tplName := site.b.listTemplate()
+		if _, ok := site.b.templates[tplName]; !ok {
+			DEBUG("%s: no index page, nor %s template", folder.URL(), tplName)
+			return
+		}
+		p = site.listPage(folder.URL(), folder.Title(), "")
+		p.Folder = folder
+		p.Meta["Template"] = tplName
+		for _, pa := range folder.Pages {
+			if pa.ModTime.After(p.ModTime) {
+				p.ModTime = pa.ModTime
+			}
+		}
+	} else if !p.rendered {
+		return
+	}
+
+	tplName := p.Meta["Template"]
+	tpl, ok := site.b.templates[tplName]
+	if !ok {
+		site.errorf("%s: template not found: %s", folder.URL(), tplName)
+		return
+	}
+	pagers := site.paginate(folder.URL(), folder.listed())
+	p.Pager = pagers[0]
+	res, err := site.execute(tpl, p)
+	if err != nil {
+		site.errorf("%s: %v", folder.URL(), err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(folder.GetOutDir(), "index.html"), res, 0644); err != nil {
+		site.errorf("%s: %v", folder.URL(), err)
+		return
+	}
+	folder.legit("index.html")
+	site.listings = append(site.listings, p)
+	site.writePagers(folder.GetOutDir(), p, tpl, pagers)
+}
@@ -0,0 +1,68 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListPages(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := writeTree(t, map[string]string{
+		"templates/default.html": `{{.Content}}`,
+		"templates/list.html":    `{{.Meta.Title}}|{{.Content}}|{{range .Folder.Subdirs}}{{.Title}} {{.URL}},{{end}}|{{range .Pager.Pages}}{{.Meta.Title}},{{end}}`,
+		"src/a.md":               "---\nTitle: a\nDate: 2019-01-01\n---\n[blog](blog/_index.md)\n",
+		"src/blog/_index.md":     "---\nTitle: The blog\n---\nAll *posts*\n",
+		"src/blog/b.md":          "---\nTitle: b\nDate: 2019-01-01\n---\nB\n",
+		"src/blog/c.md":          "---\nTitle: c\nDate: 2019-02-01\n---\nC\n",
+		"src/blog/old/d.md":      "---\nTitle: d\nDate: 2018-01-01\n---\nD\n",
+		"src/home/index.md":      "---\nTitle: home\nIndex: yes\n---\nHome\n",
+	})
+	defer os.RemoveAll(dir)
+
+	b, err := New(Config{RootDir: dir, Src: "src", Out: "out", Template: "templates", SiteName: "Site"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func() {
+		for name, expect := range map[string]string{
+			"index.html":          "Site||The blog /blog/,home /home/,|a,",
+			"blog/index.html":     "The blog|<p>All <em>posts</em></p>\n|old /blog/old/,|c,b,",
+			"blog/old/index.html": "old|||d,",
+			"home/index.html":     "<p>Home</p>",
+			"a":                   `<p><a href="/blog/">blog</a></p>`,
+		} {
+			content, err := ioutil.ReadFile(filepath.Join(dir, "out", filepath.FromSlash(name)))
+			if err != nil {
+				t.Error(err)
+			} else if got := strings.TrimSpace(string(content)); got != expect {
+				t.Errorf("%s: expected %q, got %q", name, expect, got)
+			}
+		}
+	}
+	if _, err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	check()
+	if _, err := os.Stat(filepath.Join(dir, "out", "blog", "_index")); !os.IsNotExist(err) {
+		t.Error("expected no page for _index.md")
+	}
+	if feed, _ := ioutil.ReadFile(filepath.Join(dir, "out", "rss")); strings.Contains(string(feed), "The blog") {
+		t.Error("expected no feed item for _index.md")
+	}
+
+	// generated index pages are kept by the next builds
+	if _, err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	check()
+}
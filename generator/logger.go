@@ -0,0 +1,34 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"log"
+	"runtime/debug"
+)
+
+// Enable debug (and warning) output
+var Debug bool
+
+func INFO(format string, args ...interface{}) {
+	log.Printf("[INFO]"+format, args...)
+}
+
+func WARN(format string, args ...interface{}) {
+	if Debug {
+		log.Printf("[WARN]"+format, args...)
+	}
+}
+
+func DEBUG(format string, args ...interface{}) {
+	if Debug {
+		log.Printf("[DEBUG]"+format, args...)
+	}
+}
+
+func ERROR(format string, args ...interface{}) {
+	debug.PrintStack()
+	log.Printf("[ERROR]"+format, args...)
+}
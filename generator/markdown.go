@@ -0,0 +1,143 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Markdown engine renders the markdown source of a page to HTML
+type Markdown interface {
+	Render(src []byte) ([]byte, error)
+}
+
+// Options tweaking a markdown engine, by name, ie. "footnotes" or "smartypants=false"
+type MarkdownOptions map[string]bool
+
+// Create a Markdown engine with its options, fenced code blocks are rendered
+// by hl when not nil
+type MarkdownFactory func(opts MarkdownOptions, hl *Highlighter) (Markdown, error)
+
+// The markdown engine used when none is configured
+const DefaultMarkdown = "blackfriday"
+
+// Registered markdown engines, by name
+var markdowns = map[string]MarkdownFactory{
+	"blackfriday": newBlackfriday,
+	"goldmark":    newGoldmark,
+}
+
+// Register a markdown engine, an engine of the same name is replaced
+func RegisterMarkdown(name string, f MarkdownFactory) {
+	markdowns[name] = f
+}
+
+// Parse a markdown spec as found in the configuration or in the `Markdown`
+// front matter key: a comma separated list of an engine name and options,
+// ie. "goldmark, footnotes, typographer=false".
+func parseMarkdownSpec(spec string) (engine string, opts MarkdownOptions, err error) {
+	opts = make(MarkdownOptions)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if _, ok := markdowns[item]; ok {
+			if engine != "" && engine != item {
+				return "", nil, fmt.Errorf("more than one markdown engine: %s, %s", engine, item)
+			}
+			engine = item
+			continue
+		}
+		name, val := item, true
+		if i := strings.Index(item, "="); i >= 0 {
+			name = strings.TrimSpace(item[:i])
+			val, err = strconv.ParseBool(strings.TrimSpace(item[i+1:]))
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid markdown option %s", item)
+			}
+		}
+		opts[strings.ToLower(name)] = val
+	}
+	return engine, opts, nil
+}
+
+// Create the markdown engine of a page spec, based on the site spec.
+// The page inherits the site's options unless it selects another engine.
+func newMarkdown(siteSpec, pageSpec string, hl *Highlighter) (Markdown, error) {
+	engine, opts, err := parseMarkdownSpec(siteSpec)
+	if err != nil {
+		return nil, err
+	}
+	if engine == "" {
+		engine = DefaultMarkdown
+	}
+	pageEngine, pageOpts, err := parseMarkdownSpec(pageSpec)
+	if err != nil {
+		return nil, err
+	}
+	if pageEngine != "" && pageEngine != engine {
+		engine, opts = pageEngine, make(MarkdownOptions)
+	}
+	for k, v := range pageOpts {
+		opts[k] = v
+	}
+	return markdowns[engine](opts, hl)
+}
+
+// Return the value of an option, or def when not set
+func (opts MarkdownOptions) get(name string, def bool) bool {
+	if v, ok := opts[name]; ok {
+		return v
+	}
+	return def
+}
+
+// Check all options are known to the engine
+func (opts MarkdownOptions) check(engine string, known ...string) error {
+	var unknown []string
+	for name := range opts {
+		found := false
+		for _, k := range known {
+			if name == k {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown %s option(s): %s", engine, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// Render the markdown content of a page, with its own engine if the page has
+// a `Markdown` key, and expand its shortcodes
+func (b *Builder) renderMarkdown(p *PAGE) ([]byte, error) {
+	md := b.markdown
+	if spec, ok := p.Meta["Markdown"]; ok {
+		var err error
+		if md, err = newMarkdown(b.Config.Markdown, spec, b.highlighter); err != nil {
+			return nil, err
+		}
+	}
+	sc := &scExpander{b: b, page: p, md: md}
+	src, err := sc.expand(markMore(p.buf.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	res, err := md.Render(src)
+	if err != nil {
+		return nil, err
+	}
+	return unmarkMore(sc.replace(res)), nil
+}
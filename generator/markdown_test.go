@@ -0,0 +1,120 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMarkdownSpec(t *testing.T) {
+	engine, opts, err := parseMarkdownSpec(" goldmark, Footnotes, typographer=false ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if engine != "goldmark" {
+		t.Errorf("expected goldmark, got %q", engine)
+	}
+	if len(opts) != 2 || !opts["footnotes"] || opts["typographer"] {
+		t.Errorf("unexpected options %v", opts)
+	}
+
+	for _, spec := range []string{"goldmark, blackfriday", "footnotes=maybe"} {
+		if _, _, err := parseMarkdownSpec(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
+
+func TestNewMarkdown(t *testing.T) {
+	cases := []struct {
+		site, page string
+		ok         bool
+	}{
+		{"", "", true},
+		{"goldmark, hardwraps", "", true},
+		{"goldmark, hardwraps", "typographer=false", true},
+		// the page selects another engine, site options are not inherited
+		{"goldmark, hardwraps", "blackfriday", true},
+		{"", "footnotes, smartypants=false", true},
+		{"", "tasklists", false},
+		{"goldmark", "nosuchoption", false},
+	}
+	for _, c := range cases {
+		_, err := newMarkdown(c.site, c.page, nil)
+		if (err == nil) != c.ok {
+			t.Errorf("%q/%q: unexpected error %v", c.site, c.page, err)
+		}
+	}
+}
+
+func TestGoldmark(t *testing.T) {
+	md, err := newMarkdown("goldmark", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := `# A title
+
+- [x] done
+- [ ] todo
+
+Term
+: Definition
+
+Text[^1]
+
+[^1]: A note
+`
+	res, err := md.Render([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expect := range []string{
+		`<h1 id="a-title">A title</h1>`,
+		`<input checked="" disabled="" type="checkbox" /> done`,
+		`<dt>Term</dt>`,
+		`<dd>Definition</dd>`,
+		`class="footnote-ref"`,
+	} {
+		if !strings.Contains(string(res), expect) {
+			t.Errorf("expected %s in:\n%s", expect, res)
+		}
+	}
+}
+
+func TestMarkdownOptions(t *testing.T) {
+	cases := []struct {
+		spec, src, expect string
+	}{
+		{"", `"quoted"`, "&ldquo;quoted&rdquo;"},
+		{"smartypants=false", `"quoted"`, "&quot;quoted&quot;"},
+		{"angledquotes", `"cité"`, "&laquo;cité&raquo;"},
+		{"latexdashes", "a -- b --- c", "a &ndash; b &mdash; c"},
+		{"footnotes", "a[^1]\n\n[^1]: note", `<div class="footnotes">`},
+		{"headingids", "# A title", `<h1 id="a-title">A title</h1>`},
+		{"unsafe=false", "<b>bold</b>", "<p>bold</p>"},
+		{"goldmark", `"quoted"`, "&ldquo;quoted&rdquo;"},
+		{"goldmark, smartypants=false", `"quoted"`, "&quot;quoted&quot;"},
+		{"goldmark, angledquotes, quotesnbsp", `"cité"`, "&laquo;&nbsp;cité&nbsp;&raquo;"},
+		{"goldmark, dashes=false", "a -- b", "a -- b"},
+		{"goldmark, latexdashes", "a -- b --- c", "a &ndash; b &mdash; c"},
+		{"goldmark, latexdashes=false", "a -- b --- c", "a &mdash; b &mdash; c"},
+	}
+	for _, c := range cases {
+		md, err := newMarkdown("", c.spec, nil)
+		if err != nil {
+			t.Errorf("%q: %v", c.spec, err)
+			continue
+		}
+		res, err := md.Render([]byte(c.src))
+		if err != nil {
+			t.Errorf("%q: %v", c.spec, err)
+			continue
+		}
+		if !strings.Contains(string(res), c.expect) {
+			t.Errorf("%q: expected %s in %s", c.spec, c.expect, res)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/minify/v2/css"
+	"github.com/tdewolff/minify/v2/html"
+	"github.com/tdewolff/minify/v2/js"
+	"github.com/tdewolff/minify/v2/svg"
+)
+
+// Create the minifier of CSS, JS, SVG and HTML
+func newMinifier() *minify.M {
+	m := minify.New()
+	m.AddFunc("text/css", css.Minify)
+	m.AddFuncRegexp(regexp.MustCompile(`^(application|text)/(x-)?(java|ecma)script$`), js.Minify)
+	m.AddFunc("image/svg+xml", svg.Minify)
+	m.Add("text/html", &html.Minifier{KeepDocumentTags: true, KeepEndTags: true, KeepQuotes: true})
+	return m
+}
+
+// Return the media type of a file name which can be minified, or ""
+func minifiable(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".css":
+		return "text/css"
+	case ".js", ".mjs":
+		return "application/javascript"
+	case ".svg":
+		return "image/svg+xml"
+	case ".html", ".htm":
+		return "text/html"
+	}
+	return ""
+}
+
+// Minify content of the given file name when configured to, content which
+// cannot be minified is returned as is
+func (b *Builder) minifyContent(name string, content []byte) ([]byte, error) {
+	if b.minifier == nil {
+		return content, nil
+	}
+	mt := minifiable(name)
+	if mt == "" {
+		return content, nil
+	}
+	res, err := b.minifier.Bytes(mt, content)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,136 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+)
+
+// Default number of pages listed by a page of a listing
+const DefaultPageSize = 10
+
+// Sub-directory of the pages 2 and more of a listing, ie. /blog/page/2/
+const pagerDir = "page"
+
+// A page of a listing: the home page of a folder or the page of an author
+type Pager struct {
+	Number int    // number of the page, from 1
+	URL    string // URL of the page, ie. /blog/page/2/
+	Pages  PAGES  // pages listed by this page
+
+	pagers []*Pager // all pages of the listing
+}
+
+// Number of pages of the listing
+func (pg *Pager) TotalPages() int {
+	return len(pg.pagers)
+}
+
+// Previous page, nil on the first one
+func (pg *Pager) Prev() *Pager {
+	if pg.Number <= 1 {
+		return nil
+	}
+	return pg.pagers[pg.Number-2]
+}
+
+// Next page, nil on the last one
+func (pg *Pager) Next() *Pager {
+	if pg.Number >= len(pg.pagers) {
+		return nil
+	}
+	return pg.pagers[pg.Number]
+}
+
+// First page of the listing
+func (pg *Pager) First() *Pager {
+	return pg.pagers[0]
+}
+
+// Last page of the listing
+func (pg *Pager) Last() *Pager {
+	return pg.pagers[len(pg.pagers)-1]
+}
+
+// All pages of the listing, ie. for numbered links
+func (pg *Pager) Pagers() []*Pager {
+	return pg.pagers
+}
+
+// Pages of the listing at most n pages away from this one
+func (pg *Pager) Around(n int) []*Pager {
+	from, to := pg.Number-1-n, pg.Number+n
+	if from < 0 {
+		from = 0
+	}
+	if to > len(pg.pagers) {
+		to = len(pg.pagers)
+	}
+	return pg.pagers[from:to]
+}
+
+// Split the pages of a listing at the URL path of a directory in pages of
+// PageSize pages, there is always a first page
+func (site *Site) paginate(urlPath string, pages PAGES) []*Pager {
+	size := site.b.Config.PageSize
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	var pagers []*Pager
+	for i := 0; i == 0 || i < len(pages); i += size {
+		pg := &Pager{Number: len(pagers) + 1, URL: path.Clean(urlPath)}
+		if pg.Number > 1 {
+			pg.URL = path.Join(pg.URL, pagerDir, strconv.Itoa(pg.Number))
+		}
+		if pg.URL != "/" {
+			pg.URL += "/"
+		}
+		end := i + size
+		if end > len(pages) {
+			end = len(pages)
+		}
+		pg.Pages = pages[i:end]
+		pagers = append(pagers, pg)
+	}
+	for _, pg := range pagers {
+		pg.pagers = pagers
+	}
+	return pagers
+}
+
+// Write the pages 2 and more of the listing of p to the page sub-directory
+// of dir, the first page being p itself. Pages of a previous build beyond
+// the last one are removed.
+func (site *Site) writePagers(dir string, p *PAGE, tpl Template, pagers []*Pager) {
+	for _, pg := range pagers[1:] {
+		pp := *p
+		pp.Pager = pg
+		pdir := filepath.Join(dir, pagerDir, strconv.Itoa(pg.Number))
+		if err := os.MkdirAll(pdir, 0755); err != nil {
+			site.errorf("%s: %v", pg.URL, err)
+			continue
+		}
+		res, err := site.execute(tpl, &pp)
+		if err != nil {
+			site.errorf("%s: %v", pg.URL, err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(pdir, "index.html"), res, 0644); err != nil {
+			site.errorf("%s: %v", pg.URL, err)
+		}
+	}
+
+	files, _ := ioutil.ReadDir(filepath.Join(dir, pagerDir))
+	for _, fi := range files {
+		if n, err := strconv.Atoi(fi.Name()); err == nil && fi.IsDir() && (n < 2 || n > len(pagers)) {
+			os.RemoveAll(filepath.Join(dir, pagerDir, fi.Name()))
+		}
+	}
+	// only if empty
+	os.Remove(filepath.Join(dir, pagerDir))
+}
@@ -0,0 +1,85 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	site := &Site{b: &Builder{Config: Config{PageSize: 2}}}
+	pages := make(PAGES, 5)
+	pagers := site.paginate("/blog", pages)
+	if len(pagers) != 3 || len(pagers[2].Pages) != 1 {
+		t.Fatalf("unexpected pagers %v", pagers)
+	}
+	pg := pagers[1]
+	if pg.URL != "/blog/page/2/" || pg.Prev().URL != "/blog/" || pg.Next().URL != "/blog/page/3/" ||
+		pg.First() != pagers[0] || pg.Last() != pagers[2] || pg.TotalPages() != 3 {
+		t.Errorf("unexpected pager %+v", pg)
+	}
+	if pagers[0].Prev() != nil || pagers[2].Next() != nil {
+		t.Error("expected no page before the first one and after the last one")
+	}
+	if around := pagers[0].Around(1); len(around) != 2 || around[1] != pg {
+		t.Errorf("unexpected pages around %v", around)
+	}
+
+	// a listing without pages has an empty first page
+	if pagers := site.paginate("/", nil); len(pagers) != 1 || pagers[0].URL != "/" {
+		t.Errorf("unexpected pagers %v", pagers)
+	}
+}
+
+func TestPagination(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	files := map[string]string{
+		"templates/default.html": `{{with .Pager}}{{.Number}}/{{.TotalPages}}:{{range .Pages}}{{.Meta.Title}},{{end}}{{with .Next}}{{.URL}}{{end}}{{end}}`,
+		"src/blog/index.md":      "---\nTitle: blog\nIndex: yes\nDate: 2019-01-01\n---\nBlog\n",
+	}
+	for i := 1; i <= 5; i++ {
+		files[fmt.Sprintf("src/blog/post%d.md", i)] = fmt.Sprintf("---\nTitle: post%d\nDate: 2019-02-0%d\n---\nPost\n", i, i)
+	}
+	dir := writeTree(t, files)
+	defer os.RemoveAll(dir)
+
+	b, err := New(Config{RootDir: dir, Src: "src", Out: "out", Template: "templates", PageSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	for name, expect := range map[string]string{
+		"blog/index.html":        "1/3:post5,post4,/blog/page/2/",
+		"blog/page/2/index.html": "2/3:post3,post2,/blog/page/3/",
+		"blog/page/3/index.html": "3/3:post1,",
+		"blog/post1":             "",
+	} {
+		content, err := ioutil.ReadFile(filepath.Join(dir, "out", filepath.FromSlash(name)))
+		if err != nil {
+			t.Error(err)
+		} else if got := strings.TrimSpace(string(content)); got != expect {
+			t.Errorf("%s: expected %q, got %q", name, expect, got)
+		}
+	}
+
+	// pages beyond the last one are removed
+	b.Config.PageSize = 3
+	if _, err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out", "blog", "page", "3")); !os.IsNotExist(err) {
+		t.Error("expected the third page to be removed")
+	}
+}
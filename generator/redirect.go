@@ -0,0 +1,136 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// A redirect map format: the file written to Out and the format of its
+// lines, from the old URL path to the new one
+type redirectFormat struct {
+	file string
+	line string
+}
+
+// Redirect map formats, by name
+var redirectFormats = map[string]redirectFormat{
+	"nginx":   {"redirects.map", "%s %s;\n"}, // included by a map block, ie. map $uri $redirect { include redirects.map; }
+	"apache":  {".htaccess", "Redirect 301 %s %s\n"},
+	"netlify": {"_redirects", "%s %s 301\n"},
+}
+
+// Check the redirect map formats
+func checkRedirectFormats(formats []string) error {
+	for _, f := range formats {
+		if _, ok := redirectFormats[f]; !ok {
+			return fmt.Errorf("unknown redirect format %q", f)
+		}
+	}
+	return nil
+}
+
+// The redirect stub written at the aliases of a page, with its absolute URL
+var aliasTemplate = template.Must(template.New("alias").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<meta http-equiv="refresh" content="0; url={{.}}">
+<link rel="canonical" href="{{.}}">
+<title>{{.}}</title>
+</head>
+<body><a href="{{.}}">{{.}}</a></body>
+</html>
+`))
+
+// A redirect from an alias of a page to its URL
+type redirect struct {
+	from, to string
+}
+
+// Return the redirects from the aliases of the pages (Aliases key, absolute
+// or relative to their folder) to their URL, by alias
+func (site *Site) redirects() []redirect {
+	var srcs []string
+	for src, p := range site.pages {
+		if p.rendered {
+			srcs = append(srcs, src)
+		}
+	}
+	sort.Strings(srcs)
+
+	var res []redirect
+	seen := make(map[string]string)
+	for _, src := range srcs {
+		p := site.pages[src]
+		for _, alias := range p.Meta.List("Aliases") {
+			if err := p.Folder.checkURL(alias); err != nil {
+				site.errorf("%s: alias %v", src, err)
+				continue
+			}
+			from := p.Folder.resolveURL(alias)
+			if other, ok := seen[from]; ok {
+				site.errorf("%s: alias %s is already an alias of %s", src, alias, other)
+				continue
+			}
+			if site.written[outFile(from)] || from == p.URL() {
+				site.errorf("%s: alias %s is the URL of a page", src, alias)
+				continue
+			}
+			if site.b.outputs[outFile(from)] {
+				site.errorf("%s: alias %s is the URL of a static file", src, alias)
+				continue
+			}
+			seen[from] = src
+			res = append(res, redirect{from, p.URL()})
+		}
+	}
+	return res
+}
+
+// Write a redirect stub at the aliases of the pages, and the redirect maps of
+// the configured formats, return the redirects
+func (site *Site) writeRedirects() []redirect {
+	b := site.b
+	redirects := site.redirects()
+	for _, r := range redirects {
+		var buf bytes.Buffer
+		if err := aliasTemplate.Execute(&buf, b.absURL(r.to)); err != nil {
+			site.errorf("alias %s: %v", r.from, err)
+			continue
+		}
+		fpath := filepath.Join(b.PublicDir, filepath.FromSlash(outFile(r.from)))
+		if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+			site.errorf("alias %s: %v", r.from, err)
+			continue
+		}
+		if err := ioutil.WriteFile(fpath, buf.Bytes(), 0644); err != nil {
+			site.errorf("alias %s: %v", r.from, err)
+		}
+	}
+
+	for _, name := range b.Config.Redirects {
+		f := redirectFormats[name]
+		if b.outputs["/"+f.file] {
+			WARN("%s redirects not written, %s is a static file", name, f.file)
+			continue
+		}
+		var buf bytes.Buffer
+		for _, r := range redirects {
+			fmt.Fprintf(&buf, f.line, r.from, r.to)
+		}
+		if err := ioutil.WriteFile(filepath.Join(b.PublicDir, f.file), buf.Bytes(), 0644); err != nil {
+			site.errorf("%s redirects: %v", name, err)
+		}
+	}
+	return redirects
+}
@@ -0,0 +1,106 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAliases(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := writeTree(t, map[string]string{
+		"templates/default.html": `{{.Content}}`,
+		"src/sub/new.md":         "---\nTitle: new\nAliases: old, /2019/old.html,/blog/old/\n---\nNew\n",
+		"src/sub/other.md":       "---\nTitle: other\nAliases: new\n---\nOther\n",
+	})
+	defer os.RemoveAll(dir)
+
+	b, err := New(Config{RootDir: dir, Src: "src", Out: "out", Template: "templates", BaseURL: "http://example.com/",
+		Redirects: []string{"nginx", "apache", "netlify"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := b.Build()
+	if len(res.Errors) != 1 || !strings.Contains(err.Error(), "alias new is the URL of a page") {
+		t.Errorf("unexpected errors %v", res.Errors)
+	}
+	if expect := map[string]string{"/sub/old": "/sub/new", "/2019/old.html": "/sub/new", "/blog/old/": "/sub/new"}; !reflect.DeepEqual(res.Redirects, expect) {
+		t.Errorf("expected redirects %v, got %v", expect, res.Redirects)
+	}
+	read := func(name string) string {
+		content, err := ioutil.ReadFile(filepath.Join(dir, "out", filepath.FromSlash(name)))
+		if err != nil {
+			t.Error(err)
+		}
+		return string(content)
+	}
+	for _, name := range []string{"sub/old", "2019/old.html", "blog/old/index.html"} {
+		if stub := read(name); !strings.Contains(stub, `<meta http-equiv="refresh" content="0; url=http://example.com/sub/new">`) {
+			t.Errorf("%s: unexpected stub %s", name, stub)
+		}
+	}
+	for name, expect := range map[string]string{
+		"redirects.map": "/sub/old /sub/new;\n/2019/old.html /sub/new;\n/blog/old/ /sub/new;\n",
+		".htaccess":     "Redirect 301 /sub/old /sub/new\nRedirect 301 /2019/old.html /sub/new\nRedirect 301 /blog/old/ /sub/new\n",
+		"_redirects":    "/sub/old /sub/new 301\n/2019/old.html /sub/new 301\n/blog/old/ /sub/new 301\n",
+	} {
+		if got := read(name); got != expect {
+			t.Errorf("%s: expected %q, got %q", name, expect, got)
+		}
+	}
+
+	// stubs of removed aliases are removed
+	if err := ioutil.WriteFile(filepath.Join(dir, "src", "sub", "new.md"), []byte("---\nTitle: new\n---\nNew\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b.Build()
+	if _, err := os.Stat(filepath.Join(dir, "out", "sub", "old")); !os.IsNotExist(err) {
+		t.Error("expected the stub to be removed")
+	}
+
+	if _, err := New(Config{Redirects: []string{"iis"}}); err == nil {
+		t.Error("expected an error on an unknown redirect format")
+	}
+}
+
+func TestAliasErrors(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := writeTree(t, map[string]string{
+		"templates/default.html": `{{.Content}}`,
+		"src/sub/page.md":        "---\nTitle: page\nAliases: /../../x, ../../y, /a/./../ok, /logo.png\n---\nPage\n",
+		"static/logo.png":        "logo",
+	})
+	defer os.RemoveAll(dir)
+
+	b, err := New(Config{RootDir: dir, Src: "src", Out: "out", Template: "templates", Static: []StaticDir{{Dir: "static"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := b.Build()
+	if err == nil || len(res.Errors) != 3 || !strings.Contains(res.Errors[2].Error(), "alias /logo.png is the URL of a static file") {
+		t.Errorf("unexpected errors %v", res.Errors)
+	}
+	if expect := map[string]string{"/ok": "/sub/page"}; !reflect.DeepEqual(res.Redirects, expect) {
+		t.Errorf("expected redirects %v, got %v", expect, res.Redirects)
+	}
+	for _, name := range []string{"x", "y", "../x", "../y"} {
+		if _, err := os.Stat(filepath.Join(dir, "out", name)); !os.IsNotExist(err) {
+			t.Errorf("unexpected stub %s", name)
+		}
+	}
+	if logo, err := ioutil.ReadFile(filepath.Join(dir, "out", "logo.png")); err != nil || string(logo) != "logo" {
+		t.Errorf("unexpected static file %q %v", logo, err)
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package generator
 
 // Adapted from https://github.com/krautchan/gbt
 //
@@ -89,9 +89,12 @@ func (ch *Channel) AppendItem(i *Item) {
 	ch.Item = append(ch.Item, i)
 }
 
-// Writes the data in RSS 2.0 format to a given file
+// Writes the data in RSS 2.0 format to a given file, the last build date
+// defaults to now
 func (rss *Rss) WriteToFile(path string) error {
-	rss.Channels[0].LastBuildDate = time.Now().Format(time.RFC822)
+	if rss.Channels[0].LastBuildDate == "" {
+		rss.Channels[0].LastBuildDate = time.Now().Format(time.RFC822)
+	}
 	file, err := os.Create(path)
 	if err != nil {
 		return err
@@ -0,0 +1,197 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Shortcode templates sub-directory of TemplatesDir
+const shortcodesDir = "shortcodes"
+
+// Data passed to a shortcode template
+type Shortcode struct {
+	Name   string            // shortcode name, ie. figure
+	Params map[string]string // named parameters
+	Args   []string          // positional parameters
+	Inner  template.HTML     // rendered markdown between the opening and closing tags
+	Page   *PAGE             // the page using the shortcode
+}
+
+// Return a parameter, or def if not set
+func (sc *Shortcode) Get(name, def string) string {
+	if v, ok := sc.Params[name]; ok {
+		return v
+	}
+	return def
+}
+
+// Return the processed image of the `src` parameter, nil if there is none
+func (sc *Shortcode) Image() *ImageSet {
+	return sc.Page.Image(sc.Params["src"])
+}
+
+var (
+	// {{< name params >}}, {{< /name >}} or the escaped form {{</* name */>}}
+	rxShortcode = regexp.MustCompile(`(?s)\{\{<\s*(/?)\s*([\w-]+|/\*.*?\*/)(.*?)\s*>\}\}`)
+	rxSCParam   = regexp.MustCompile(`(?:([\w-]+)\s*=\s*)?("(?:[^"\\]|\\.)*"|[^\s"]+)`)
+)
+
+// Compile the shortcode templates, if any
+func (b *Builder) compileShortcodes(tplDir string) error {
+	dir := filepath.Join(tplDir, shortcodesDir)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		b.shortcodes = nil
+		return nil
+	}
+	tpls, err := b.compileDir(dir)
+	if err != nil {
+		return err
+	}
+	b.shortcodes = tpls
+	return nil
+}
+
+// Shortcode expansion of a page: shortcodes are replaced by placeholders
+// before the markdown rendering, and by their HTML after.
+type scExpander struct {
+	b    *Builder
+	page *PAGE
+	md   Markdown
+	html [][]byte // rendered shortcodes, by placeholder number
+}
+
+// Return the placeholder of the n-th shortcode, which markdown leaves as is
+func scPlaceholder(n int) string {
+	return fmt.Sprintf("JFEVERSC%04dX", n)
+}
+
+// Replace all shortcodes of src by placeholders
+func (e *scExpander) expand(src []byte) ([]byte, error) {
+	var out bytes.Buffer
+	for {
+		loc := rxShortcode.FindSubmatchIndex(src)
+		if loc == nil {
+			out.Write(src)
+			return out.Bytes(), nil
+		}
+		out.Write(src[:loc[0]])
+		closing := loc[3] > loc[2]
+		name := string(src[loc[4]:loc[5]])
+		params := string(src[loc[6]:loc[7]])
+
+		// escaped shortcode, written as is without its comment marks
+		if strings.HasPrefix(name, "/*") {
+			literal := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(name+params, "/*"), "*/"))
+			out.WriteString(scPlaceholder(len(e.html)))
+			e.html = append(e.html, []byte(html.EscapeString("{{< "+literal+" >}}")))
+			src = src[loc[1]:]
+			continue
+		}
+		if closing {
+			return nil, fmt.Errorf("unexpected shortcode closing tag %s", name)
+		}
+
+		sc := &Shortcode{Name: name, Page: e.page}
+		sc.Params, sc.Args = parseSCParams(params)
+		rest := src[loc[1]:]
+		if start, end := findClosingSC(rest, name); start >= 0 {
+			inner, err := e.expand(rest[:start])
+			if err != nil {
+				return nil, err
+			}
+			res, err := e.md.Render(inner)
+			if err != nil {
+				return nil, err
+			}
+			sc.Inner = template.HTML(e.replace(res))
+			rest = rest[end:]
+		}
+
+		res, err := e.render(sc)
+		if err != nil {
+			return nil, err
+		}
+		out.WriteString(scPlaceholder(len(e.html)))
+		e.html = append(e.html, res)
+		src = rest
+	}
+}
+
+// Find the closing tag of shortcode name in src, taking nested shortcodes of
+// the same name into account. Return the start and end of the closing tag, or -1.
+func findClosingSC(src []byte, name string) (int, int) {
+	depth := 0
+	for _, loc := range rxShortcode.FindAllSubmatchIndex(src, -1) {
+		if string(src[loc[4]:loc[5]]) != name {
+			continue
+		}
+		if loc[3] == loc[2] {
+			depth++
+		} else if depth > 0 {
+			depth--
+		} else {
+			return loc[0], loc[1]
+		}
+	}
+	return -1, -1
+}
+
+// Parse shortcode parameters: name="value", name=value or positional values
+func parseSCParams(s string) (map[string]string, []string) {
+	params := make(map[string]string)
+	var args []string
+	for _, m := range rxSCParam.FindAllStringSubmatch(s, -1) {
+		v := m[2]
+		if strings.HasPrefix(v, `"`) {
+			if uq, err := strconv.Unquote(v); err == nil {
+				v = uq
+			} else {
+				v = strings.Trim(v, `"`)
+			}
+		}
+		if m[1] != "" {
+			params[m[1]] = v
+		} else {
+			args = append(args, v)
+		}
+	}
+	return params, args
+}
+
+// Render a shortcode with its template
+func (e *scExpander) render(sc *Shortcode) ([]byte, error) {
+	tpl, ok := e.b.shortcodes[sc.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown shortcode %s", sc.Name)
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, sc); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(buf.Bytes()), nil
+}
+
+// Replace placeholders in rendered HTML by their shortcode, a placeholder
+// alone in its paragraph replaces the paragraph.
+func (e *scExpander) replace(res []byte) []byte {
+	for n := len(e.html) - 1; n >= 0; n-- {
+		ph := []byte(scPlaceholder(n))
+		if !bytes.Contains(res, ph) {
+			continue
+		}
+		res = bytes.Replace(res, []byte("<p>"+string(ph)+"</p>"), e.html[n], -1)
+		res = bytes.Replace(res, ph, e.html[n], -1)
+	}
+	return res
+}
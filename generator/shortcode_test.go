@@ -0,0 +1,66 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"html/template"
+	"reflect"
+	"testing"
+)
+
+func TestParseSCParams(t *testing.T) {
+	params, args := parseSCParams(` "first arg" src=/a.jpg caption="with \"quotes\"" second`)
+	expect := map[string]string{"src": "/a.jpg", "caption": `with "quotes"`}
+	if !reflect.DeepEqual(params, expect) {
+		t.Errorf("expected %v, got %v", expect, params)
+	}
+	if !reflect.DeepEqual(args, []string{"first arg", "second"}) {
+		t.Errorf("unexpected positional parameters %v", args)
+	}
+}
+
+func TestFindClosingSC(t *testing.T) {
+	src := []byte(`a {{< box >}}b{{< /box >}} c {{< /other >}}{{< /box >}}`)
+	start, end := findClosingSC(src, "box")
+	if string(src[start:end]) != "{{< /box >}}" || start != 43 {
+		t.Errorf("unexpected closing tag at %d-%d", start, end)
+	}
+	if start, _ := findClosingSC(src, "none"); start != -1 {
+		t.Errorf("expected no closing tag, got %d", start)
+	}
+}
+
+func TestExpandShortcodes(t *testing.T) {
+	md, err := newMarkdown("", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &Builder{shortcodes: map[string]Template{
+		"em": template.Must(template.New("em.amber").Parse(`<em>{{index .Args 0}}</em>`)),
+		"box": template.Must(template.New("box.amber").Parse(
+			`<div class="{{.Get "class" "box"}}">{{.Inner}}</div>`)),
+	}}
+
+	e := &scExpander{b: b, md: md}
+	src, err := e.expand([]byte("A {{< em word >}}.\n\n{{<box>}}*in*{{</box>}}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := md.Render(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "<p>A <em>word</em>.</p>\n\n<div class=\"box\"><p><em>in</em></p>\n</div>\n"
+	if got := string(e.replace(res)); got != expect {
+		t.Errorf("expected %q, got %q", expect, got)
+	}
+
+	for _, src := range []string{"{{< /box >}}", "{{< nosuch >}}"} {
+		e := &scExpander{b: b, md: md}
+		if _, err := e.expand([]byte(src)); err == nil {
+			t.Errorf("%s: expected an error", src)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Max number of URLs of a sitemap, a bigger site gets a sitemap index
+var sitemapMaxURLs = 50000
+
+const (
+	sitemapFile = "sitemap.xml"
+	robotsFile  = "robots.txt"
+	sitemapNS   = "http://www.sitemaps.org/schemas/sitemap/0.9"
+)
+
+// Valid ChangeFreq keys of the pages
+var changeFreqs = map[string]bool{
+	"always": true, "hourly": true, "daily": true, "weekly": true, "monthly": true, "yearly": true, "never": true,
+}
+
+// A sitemap
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	NS      string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+// A URL of a sitemap
+type sitemapURL struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod,omitempty"`
+	ChangeFreq string `xml:"changefreq,omitempty"`
+	Priority   string `xml:"priority,omitempty"`
+
+	modTime time.Time
+}
+
+// A sitemap index, listing the sitemaps of a big site
+type sitemapIndex struct {
+	XMLName  xml.Name         `xml:"sitemapindex"`
+	NS       string           `xml:"xmlns,attr"`
+	Sitemaps []sitemapPointer `xml:"sitemap"`
+}
+
+// A sitemap of a sitemap index
+type sitemapPointer struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// Format a time in the W3C datetime format of sitemaps, in UTC, empty if zero
+func w3cTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Return the sitemap entry of a page at the URL path u, false if the page is
+// a draft or is not to be indexed
+func (site *Site) sitemapURL(p *PAGE, u string) (sitemapURL, bool) {
+	if p.Meta.Bool("Draft", false) || p.Meta.Bool("NoIndex", false) {
+		return sitemapURL{}, false
+	}
+	res := sitemapURL{Loc: site.b.absURL(u), LastMod: w3cTime(p.ModTime), modTime: p.ModTime}
+	if cf := p.Meta["ChangeFreq"]; cf != "" {
+		if changeFreqs[cf] {
+			res.ChangeFreq = cf
+		} else {
+			WARN("%s: invalid ChangeFreq %s", u, cf)
+		}
+	}
+	if pr := p.Meta["Priority"]; pr != "" {
+		if f, err := strconv.ParseFloat(pr, 64); err == nil && f >= 0 && f <= 1 {
+			res.Priority = strconv.FormatFloat(f, 'f', -1, 64)
+		} else {
+			WARN("%s: invalid Priority %s", u, pr)
+		}
+	}
+	return res, true
+}
+
+// Return the sitemap entries of the generated pages and listings, by URL
+func (site *Site) sitemapURLs() []sitemapURL {
+	var res []sitemapURL
+	for _, p := range site.pages {
+		if !p.rendered || p == p.Folder.list {
+			// _index.md pages are listings
+			continue
+		}
+		u := p.URL()
+		if p == p.Folder.index {
+			u = p.Folder.URL()
+		}
+		if e, ok := site.sitemapURL(p, u); ok {
+			res = append(res, e)
+		}
+	}
+	for _, p := range site.listings {
+		if e, ok := site.sitemapURL(p, p.URL()); ok {
+			res = append(res, e)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Loc < res[j].Loc })
+	return res
+}
+
+// Write an XML document to the file name of Out
+func (site *Site) writeXML(name string, v interface{}) error {
+	res, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	res = append([]byte(xml.Header), append(res, '\n')...)
+	return ioutil.WriteFile(filepath.Join(site.b.PublicDir, name), res, 0644)
+}
+
+// Write the sitemap of the site, split in sitemaps of at most sitemapMaxURLs
+// URLs listed by a sitemap index for big sites, and a robots.txt giving its
+// URL. Files of the static roots are kept.
+func (site *Site) writeSitemap() {
+	b := site.b
+	if b.outputs["/"+sitemapFile] {
+		DEBUG("%s is a static file", sitemapFile)
+	} else if err := site.writeSitemaps(site.sitemapURLs()); err != nil {
+		site.errorf("%s: %v", sitemapFile, err)
+	}
+
+	if b.outputs["/"+robotsFile] {
+		DEBUG("%s is a static file", robotsFile)
+		return
+	}
+	robots := fmt.Sprintf("User-agent: *\nDisallow:\n\nSitemap: %s\n", b.absURL(sitemapFile))
+	if err := ioutil.WriteFile(filepath.Join(b.PublicDir, robotsFile), []byte(robots), 0644); err != nil {
+		site.errorf("%s: %v", robotsFile, err)
+	}
+}
+
+// Write the sitemap of the URLs, or a sitemap index and its sitemaps
+func (site *Site) writeSitemaps(urls []sitemapURL) error {
+	if len(urls) <= sitemapMaxURLs {
+		return site.writeXML(sitemapFile, sitemapURLSet{NS: sitemapNS, URLs: urls})
+	}
+
+	index := sitemapIndex{NS: sitemapNS}
+	for i := 0; i < len(urls); i += sitemapMaxURLs {
+		end := i + sitemapMaxURLs
+		if end > len(urls) {
+			end = len(urls)
+		}
+		var last time.Time
+		for _, u := range urls[i:end] {
+			if u.modTime.After(last) {
+				last = u.modTime
+			}
+		}
+		name := fmt.Sprintf("sitemap-%d.xml", len(index.Sitemaps)+1)
+		if err := site.writeXML(name, sitemapURLSet{NS: sitemapNS, URLs: urls[i:end]}); err != nil {
+			return err
+		}
+		index.Sitemaps = append(index.Sitemaps, sitemapPointer{Loc: site.b.absURL(name), LastMod: w3cTime(last)})
+	}
+	return site.writeXML(sitemapFile, index)
+}
@@ -0,0 +1,106 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSitemap(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := writeTree(t, map[string]string{
+		"templates/default.html": `{{.Content}}`,
+		"templates/list.html":    `{{.Content}}`,
+		"src/index.md":           "---\nTitle: home\nIndex: yes\nChangeFreq: daily\nPriority: 1.0\n---\nHome\n",
+		"src/about.md":           "---\nTitle: about\nChangeFreq: sometimes\nPriority: 0.5\n---\nAbout\n",
+		"src/draft.md":           "---\nTitle: draft\nDraft: true\n---\nDraft\n",
+		"src/secret.md":          "---\nTitle: secret\nNoIndex: yes\n---\nSecret\n",
+		"src/blog/_index.md":     "---\nTitle: blog\n---\nBlog\n",
+		"src/blog/post.md":       "---\nTitle: post\n---\nPost\n",
+	})
+	defer os.RemoveAll(dir)
+	mtime := time.Date(2019, 10, 3, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(dir, "src", "about.md"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := New(Config{RootDir: dir, Src: "src", Out: "out", Template: "templates", BaseURL: "http://example.com/", URLs: HTMLURLs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	read := func(name string) string {
+		content, err := ioutil.ReadFile(filepath.Join(dir, "out", name))
+		if err != nil {
+			t.Error(err)
+		}
+		return string(content)
+	}
+	sitemap := read("sitemap.xml")
+	if !strings.HasPrefix(sitemap, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`) {
+		t.Errorf("unexpected sitemap %s", sitemap)
+	}
+	var locs []string
+	for _, l := range strings.Split(sitemap, "\n") {
+		if l = strings.TrimSpace(l); strings.HasPrefix(l, "<loc>") {
+			locs = append(locs, l)
+		}
+	}
+	expect := []string{
+		"<loc>http://example.com/</loc>",
+		"<loc>http://example.com/about.html</loc>",
+		"<loc>http://example.com/blog/</loc>",
+		"<loc>http://example.com/blog/post.html</loc>",
+	}
+	if strings.Join(locs, ",") != strings.Join(expect, ",") {
+		t.Errorf("expected %v, got %v", expect, locs)
+	}
+	// drafts are still generated
+	if draft := read("draft.html"); draft != "<p>Draft</p>\n" {
+		t.Errorf("unexpected draft %q", draft)
+	}
+	about := "<loc>http://example.com/about.html</loc>\n    <lastmod>2019-10-03T12:00:00Z</lastmod>\n    <priority>0.5</priority>"
+	if !strings.Contains(sitemap, about) || !strings.Contains(sitemap, "<changefreq>daily</changefreq>\n    <priority>1</priority>") {
+		t.Errorf("unexpected sitemap %s", sitemap)
+	}
+	if robots := read("robots.txt"); !strings.Contains(robots, "Sitemap: http://example.com/sitemap.xml\n") {
+		t.Errorf("unexpected robots.txt %s", robots)
+	}
+
+	// a sitemap index for big sites
+	defer func(max int) { sitemapMaxURLs = max }(sitemapMaxURLs)
+	sitemapMaxURLs = 3
+	if _, err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	index := read("sitemap.xml")
+	if !strings.Contains(index, "<sitemapindex") || !strings.Contains(index, "<loc>http://example.com/sitemap-2.xml</loc>") {
+		t.Errorf("unexpected sitemap index %s", index)
+	}
+	if sitemap := read("sitemap-2.xml"); strings.Count(sitemap, "<url>") != 1 {
+		t.Errorf("unexpected sitemap %s", sitemap)
+	}
+
+	// the sitemaps of the index are removed with it
+	sitemapMaxURLs = 50000
+	if _, err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"sitemap-1.xml", "sitemap-2.xml"} {
+		if _, err := os.Stat(filepath.Join(dir, "out", name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed", name)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A root of static content, copied to Out
+type StaticDir struct {
+	Dir    string   // the directory, absolute or relative to RootDir
+	Ignore []string // patterns of files not to copy, ie. "*.psd" or "drafts/*"
+}
+
+// Tell if a file of the root, by its path relative to the root, is ignored.
+// Patterns without a slash match the file name, others the whole path.
+func (sd StaticDir) ignored(rel string) bool {
+	for _, pattern := range sd.Ignore {
+		name := rel
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(rel)
+		}
+		if ok, _ := path.Match(strings.TrimPrefix(pattern, "/"), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Tell if a path relative to the root, or one of its parent directories, is ignored
+func (sd StaticDir) ignoredPath(rel string) bool {
+	for p := rel; p != "." && p != "/" && p != ""; p = path.Dir(p) {
+		if sd.ignored(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the file of the static roots at a logical path, ie. /css/main.css,
+// the last root having it wins
+func (b *Builder) staticFile(logical string) (string, bool) {
+	rel := strings.TrimPrefix(path.Clean("/"+logical), "/")
+	for i := len(b.StaticDirs) - 1; i >= 0; i-- {
+		sd := b.StaticDirs[i]
+		if sd.ignoredPath(rel) {
+			continue
+		}
+		fpath := filepath.Join(sd.Dir, filepath.FromSlash(rel))
+		if fi, err := os.Stat(fpath); err == nil && !fi.IsDir() {
+			return fpath, true
+		}
+	}
+	return "", false
+}
+
+// Return all files of the static roots by logical path, the files of a
+// root overriding the ones of the previous roots
+func (b *Builder) staticFiles() map[string]string {
+	files := make(map[string]string)
+	for _, sd := range b.StaticDirs {
+		root := sd.Dir
+		filepath.Walk(root, func(fpath string, fi os.FileInfo, err error) error {
+			if err != nil {
+				if fpath != root || !os.IsNotExist(err) {
+					WARN(err.Error())
+				}
+				return nil
+			}
+			rel, _ := filepath.Rel(root, fpath)
+			if rel == "." {
+				return nil
+			}
+			rel = filepath.ToSlash(rel)
+			if sd.ignored(rel) {
+				if fi.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !fi.IsDir() {
+				files["/"+rel] = fpath
+			}
+			return nil
+		})
+	}
+	return files
+}
+
+// Return the content of a file of the static roots, preprocessed if it is a
+// CSS file and configured to
+func (b *Builder) staticContent(logical string) ([]byte, error) {
+	if b.Config.PreprocessCSS && strings.ToLower(path.Ext(logical)) == ".css" {
+		return b.preprocessCSS(logical)
+	}
+	return b.readStatic(logical)
+}
+
+// Return the content of a file of the static roots as written to Out:
+// preprocessed, with the URLs of fingerprinted assets and minified, as
+// configured
+func (b *Builder) staticOutput(logical string) ([]byte, error) {
+	content, err := b.staticContent(logical)
+	if err != nil {
+		return nil, err
+	}
+	if b.rewritesCSSURLs(logical) {
+		if content, err = b.fingerprintCSSURLs(logical, content); err != nil {
+			return nil, err
+		}
+	}
+	return b.minifyContent(logical, content)
+}
+
+// Tell if the URLs of a file of the static roots are replaced by the ones of
+// fingerprinted assets: CSS files, when fingerprinting
+func (b *Builder) rewritesCSSURLs(logical string) bool {
+	return len(b.Config.Fingerprint) > 0 && strings.ToLower(path.Ext(logical)) == ".css"
+}
+
+// Read a file of the static roots
+func (b *Builder) readStatic(logical string) ([]byte, error) {
+	fpath, ok := b.staticFile(logical)
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: logical, Err: os.ErrNotExist}
+	}
+	return ioutil.ReadFile(fpath)
+}
+
+// Copy the static roots to Out, preprocessing CSS files and minifying CSS,
+// JS and SVG files when configured to. Files with a fingerprinted extension
+// also get their fingerprinted copy. Files of a previous build which are not
+// in the static roots any more are removed.
+func (site *Site) copyStatic() {
+	b := site.b
+	previous := b.outputs
+	b.outputs = make(map[string]bool)
+
+	files := b.staticFiles()
+	names := make([]string, 0, len(files))
+	for logical := range files {
+		names = append(names, logical)
+	}
+	sort.Strings(names)
+	for _, logical := range names {
+		if b.Config.PreprocessCSS && isCSSPartial(logical) {
+			continue
+		}
+		fdst := filepath.Join(b.PublicDir, filepath.FromSlash(logical))
+		if err := os.MkdirAll(filepath.Dir(fdst), 0755); err != nil {
+			site.errorf("%s: %v", logical, err)
+			continue
+		}
+		b.outputs[logical] = true
+		if b.fingerprinted(logical) {
+			if _, err := b.asset(logical); err != nil {
+				site.errorf("%s: %v", logical, err)
+			}
+		}
+		if minifiable(logical) == "" || (b.minifier == nil && !b.Config.PreprocessCSS && !b.rewritesCSSURLs(logical)) {
+			if err := copyFile(files[logical], fdst); err != nil {
+				WARN(err.Error())
+			}
+			continue
+		}
+		content, err := b.staticOutput(logical)
+		if err == nil {
+			err = ioutil.WriteFile(fdst, content, 0644)
+		}
+		if err != nil {
+			site.errorf("%s: %v", logical, err)
+		}
+	}
+
+	for logical := range previous {
+		if !b.outputs[logical] {
+			DEBUG("removing %s", logical)
+			os.Remove(filepath.Join(b.PublicDir, filepath.FromSlash(logical)))
+		}
+	}
+}
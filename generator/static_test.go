@@ -0,0 +1,84 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStaticDirs(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := writeTree(t, map[string]string{
+		"templates/default.amber":  "| #{Content}\n",
+		"src/index.md":             "---\nTitle: index\n---\nIndex\n",
+		"theme/css/main.css":       "theme main",
+		"theme/css/theme.css":      "theme",
+		"theme/img/logo.psd":       "psd",
+		"theme/drafts/new.css":     "draft",
+		"corporate/css/main.css":   "corporate main",
+		"corporate/img/logo.png":   "logo",
+		"static/favicon.ico":       "icon",
+		"static/css/main.css":      "site main",
+		"static/css/main.css.orig": "backup",
+	})
+	defer os.RemoveAll(dir)
+
+	b, err := New(Config{RootDir: dir, Src: "src", Out: "out", Template: "templates", Static: []StaticDir{
+		{Dir: "theme", Ignore: []string{"*.psd", "drafts"}},
+		{Dir: filepath.Join(dir, "corporate")},
+		{Dir: "static", Ignore: []string{"css/*.orig"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(expect map[string]string) {
+		t.Helper()
+		if _, err := b.Build(); err != nil {
+			t.Fatal(err)
+		}
+		for name, content := range expect {
+			got, err := ioutil.ReadFile(filepath.Join(dir, "out", filepath.FromSlash(name)))
+			if content == "" {
+				if !os.IsNotExist(err) {
+					t.Errorf("expected no %s, got %q", name, got)
+				}
+			} else if string(got) != content {
+				t.Errorf("expected %q in %s, got %q (%v)", content, name, got, err)
+			}
+		}
+	}
+	check(map[string]string{
+		"favicon.ico":       "icon",
+		"index":             "<p>Index</p>\n\n",
+		"css/main.css":      "site main",
+		"css/theme.css":     "theme",
+		"img/logo.png":      "logo",
+		"img/logo.psd":      "",
+		"drafts/new.css":    "",
+		"css/main.css.orig": "",
+	})
+	if _, err := b.asset("img/logo.psd"); err == nil {
+		t.Error("ignored files should not be assets")
+	}
+
+	// removing a file reveals the one of a previous root, or removes it
+	if err := os.Remove(filepath.Join(dir, "static", "css", "main.css")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "theme", "css", "theme.css")); err != nil {
+		t.Fatal(err)
+	}
+	check(map[string]string{
+		"favicon.ico":   "icon",
+		"css/main.css":  "corporate main",
+		"css/theme.css": "",
+	})
+}
@@ -0,0 +1,163 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"bytes"
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+// Default number of words of the summaries without a more marker
+const DefaultSummaryWords = 70
+
+// Reading speed of the estimated reading time, in words per minute
+const wordsPerMinute = 200
+
+// The marker ending the summary of a page, on its own line
+const moreMarker = "<!--more-->"
+
+var (
+	rxMore        = regexp.MustCompile(`^ {0,3}<!--\s*more\s*-->[ \t]*\r?$`)
+	rxFence       = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})(.*)$")
+	rxBlockTag    = regexp.MustCompile(`<(/?)([a-zA-Z][a-zA-Z0-9]*)\b[^>]*?(/?)>`)
+	rxFootnoteRef = regexp.MustCompile(`<sup[^>]*>\s*<a href="#fn:[^"]*"[^>]*>[^<]*</a>\s*</sup>`)
+	rxFootnotes   = regexp.MustCompile(`(?s)<(?:div|section) class="footnotes".*$`)
+)
+
+// Top-level blocks making the summaries, the others (code, tables, divs
+// such as the footnotes) are left out
+var summaryBlocks = map[string]bool{
+	"p": true, "ul": true, "ol": true, "dl": true, "blockquote": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// HTML elements without content
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// Placeholder of the more marker while rendering the markdown, raw HTML
+// may not be kept by the engine
+const morePlaceholder = "JFEVERMOREX"
+
+// Replace the first more marker of the markdown source by its placeholder,
+// markers in fenced or indented code are left alone
+func markMore(src []byte) []byte {
+	fence := ""
+	for start := 0; start < len(src); {
+		end := bytes.IndexByte(src[start:], '\n')
+		if end < 0 {
+			end = len(src)
+		} else {
+			end += start
+		}
+		line := src[start:end]
+		switch m := rxFence.FindSubmatch(line); {
+		case fence != "":
+			if m != nil && m[1][0] == fence[0] && len(m[1]) >= len(fence) && len(bytes.TrimSpace(m[2])) == 0 {
+				fence = ""
+			}
+		case m != nil:
+			fence = string(m[1])
+		case rxMore.Match(line):
+			return append(append(append([]byte{}, src[:start]...), "\n\n"+morePlaceholder+"\n\n"...), src[end:]...)
+		}
+		start = end + 1
+	}
+	return src
+}
+
+// Replace the placeholder of the more marker in rendered HTML by the marker
+func unmarkMore(res []byte) []byte {
+	res = bytes.Replace(res, []byte("<p>"+morePlaceholder+"</p>"), []byte(moreMarker), 1)
+	return bytes.Replace(res, []byte(morePlaceholder), []byte(moreMarker), 1)
+}
+
+// Return the top-level elements of HTML content, with their names
+func topLevelBlocks(content []byte) (names []string, blocks [][]byte) {
+	depth, start := 0, 0
+	for _, m := range rxBlockTag.FindAllSubmatchIndex(content, -1) {
+		name := strings.ToLower(string(content[m[4]:m[5]]))
+		closing := m[3] > m[2]
+		switch {
+		case closing:
+			depth--
+		case m[7] > m[6] || voidElements[name]:
+			if depth == 0 {
+				names = append(names, name)
+				blocks = append(blocks, content[m[0]:m[1]])
+			}
+			continue
+		default:
+			if depth == 0 {
+				names = append(names, name)
+				start = m[0]
+			}
+			depth++
+		}
+		if depth == 0 && closing && len(names) > len(blocks) {
+			blocks = append(blocks, content[start:m[1]])
+		}
+		if depth < 0 {
+			depth = 0
+		}
+	}
+	return names[:len(blocks)], blocks
+}
+
+// Compute the summary, word count and reading time of the rendered content
+// of a page, once its links are resolved. The summary is the content up to
+// the more marker, or its first top-level paragraphs, lists and headings up
+// to SummaryWords words, or its truncated text when it has none of these.
+func (p *PAGE) summarize(content []byte) {
+	p.WordCount = len(strings.Fields(plainify(string(content))))
+	p.ReadingTime = (p.WordCount + wordsPerMinute - 1) / wordsPerMinute
+
+	if i := bytes.Index(content, []byte(moreMarker)); i >= 0 {
+		p.Summary = template.HTML(bytes.TrimSpace(content[:i]))
+		p.Truncated = len(bytes.TrimSpace(content[i+len(moreMarker):])) > 0
+		return
+	}
+
+	max := p.Folder.Site.b.Config.SummaryWords
+	if max <= 0 {
+		max = DefaultSummaryWords
+	}
+	// the footnotes and their references are not part of the summary
+	content = rxFootnoteRef.ReplaceAll(rxFootnotes.ReplaceAll(content, nil), nil)
+	total := len(strings.Fields(plainify(string(content))))
+	var res []string
+	words, headings := 0, 0
+	names, blocks := topLevelBlocks(content)
+	for i, block := range blocks {
+		if !summaryBlocks[names[i]] {
+			continue
+		}
+		n := len(strings.Fields(plainify(string(block))))
+		if words+n > max {
+			if len(res) == headings && words < max {
+				// no text yet, the block is too long
+				res = append(res, "<p>"+html.EscapeString(summary(max-words, string(block)))+"</p>")
+				words = max
+			}
+			break
+		}
+		res = append(res, string(block))
+		words += n
+		if names[i][0] == 'h' {
+			headings++
+		}
+	}
+	if len(res) == headings && total > 0 {
+		res = []string{"<p>" + html.EscapeString(summary(max, string(content))) + "</p>"}
+		words = len(strings.Fields(plainify(res[0])))
+	}
+	p.Summary = template.HTML(strings.Join(res, "\n"))
+	p.Truncated = words < total
+}
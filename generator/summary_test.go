@@ -0,0 +1,118 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSummary(t *testing.T) {
+	page := func(words int) *PAGE {
+		site := &Site{b: &Builder{Config: Config{SummaryWords: words}}}
+		return &PAGE{Folder: &FOLDER{Site: site}}
+	}
+
+	// the more marker survives engines dropping raw HTML, and ends the
+	// paragraph it follows
+	for _, engine := range []string{"", "goldmark, unsafe=false"} {
+		md, err := newMarkdown(engine, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, src := range []string{"First *words*.\n\n<!--more-->\n\nThe rest.\n", "First *words*.\n<!--more-->\nThe rest.\n"} {
+			res, err := md.Render(markMore([]byte(src)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := page(0)
+			p.summarize(unmarkMore(res))
+			if p.Summary != "<p>First <em>words</em>.</p>" || !p.Truncated || p.WordCount != 4 || p.ReadingTime != 1 {
+				t.Errorf("%q, %q: unexpected summary %q (%v, %d words, %d min)", engine, src, p.Summary, p.Truncated, p.WordCount, p.ReadingTime)
+			}
+		}
+	}
+
+	// markers in code are left alone
+	for _, src := range []string{"```\n<!--more-->\n```\n", "~~~~ html\n<!--more-->\n~~~\n~~~~\n", "Code:\n\n    <!--more-->\n"} {
+		if res := string(markMore([]byte(src + "Text\n"))); res != src+"Text\n" {
+			t.Errorf("unexpected marked source %q", res)
+		}
+		if res := string(markMore([]byte(src + "<!--more-->\n"))); res != src+"\n\n"+morePlaceholder+"\n\n\n" {
+			t.Errorf("unexpected marked source %q", res)
+		}
+	}
+
+	// the first top-level paragraphs, lists and headings up to the max
+	// number of words
+	content := []byte("<h1>Title</h1>\n<p>One two three.</p>\n<ul>\n<li><p>Four five.</p></li>\n</ul>\n<p>Six seven eight.</p>\n")
+	p := page(6)
+	p.summarize(content)
+	if p.Summary != "<h1>Title</h1>\n<p>One two three.</p>\n<ul>\n<li><p>Four five.</p></li>\n</ul>" || !p.Truncated {
+		t.Errorf("unexpected summary %q", p.Summary)
+	}
+	p = page(3)
+	p.summarize(content)
+	if p.Summary != "<h1>Title</h1>\n<p>One two…</p>" {
+		t.Errorf("unexpected summary %q", p.Summary)
+	}
+
+	// code, tables and footnotes are left out
+	p = page(0)
+	p.summarize([]byte("<pre><code>x := 1\n</code></pre>\n<div><p>Inner.</p></div>\n<p>Text<sup id=\"fnref:1\"><a href=\"#fn:1\" class=\"footnote-ref\">1</a></sup>.</p>\n" +
+		"<div class=\"footnotes\" role=\"doc-endnotes\">\n<hr />\n<ol>\n<li id=\"fn:1\">\n<p>Note.</p>\n</li>\n</ol>\n</div>\n"))
+	if p.Summary != "<p>Text.</p>" || !p.Truncated {
+		t.Errorf("unexpected summary %q", p.Summary)
+	}
+	// the truncated text without any of these
+	p = page(2)
+	p.summarize([]byte("<pre><code>a &lt; b\nc\n</code></pre>\n"))
+	if p.Summary != "<p>a &lt;…</p>" || !p.Truncated {
+		t.Errorf("unexpected summary %q", p.Summary)
+	}
+	p = page(500)
+	p.summarize([]byte("<p>" + strings.Repeat("word ", 450) + "</p>"))
+	if p.Truncated || p.WordCount != 450 || p.ReadingTime != 3 {
+		t.Errorf("unexpected summary (%v, %d words, %d min)", p.Truncated, p.WordCount, p.ReadingTime)
+	}
+}
+
+func TestSummaryLinks(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := writeTree(t, map[string]string{
+		"templates/default.amber": "| #{Content}\n",
+		"src/a.md":                "---\nTitle: a\n---\nSee [b](sub/b.md).\n<!--more-->\nThe rest.\n",
+		"src/sub/b.md":            "---\nTitle: b\n---\nBack to [a](../a.md).\n",
+	})
+	defer os.RemoveAll(dir)
+
+	b, err := New(Config{RootDir: dir, Src: "src", Out: "out", Template: "templates", Static: []StaticDir{{Dir: "static"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the summaries of the feed link to the pages, not to their source
+	rss, err := ioutil.ReadFile(filepath.Join(dir, "out", "rss"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"See &lt;a href=&#34;/sub/b&#34;&gt;b&lt;/a&gt;.", "Back to &lt;a href=&#34;/a&#34;&gt;a&lt;/a&gt;."} {
+		if !strings.Contains(string(rss), s) {
+			t.Errorf("expected %s in the feed:\n%s", s, rss)
+		}
+	}
+	if strings.Contains(string(rss), ".md") || strings.Contains(string(rss), "The rest") {
+		t.Errorf("unexpected feed:\n%s", rss)
+	}
+}
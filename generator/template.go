@@ -0,0 +1,166 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/eknkc/amber"
+)
+
+// A template engine compiles the templates of a directory
+type TemplateEngine interface {
+	// Compile the templates of dir, by file name, ie. default.amber, funcs
+	// being available to them
+	Compile(dir string, funcs template.FuncMap) (map[string]Template, error)
+}
+
+// A compiled template, executed with a page or a shortcode
+type Template interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+type namedEngine struct {
+	name   string
+	engine TemplateEngine
+}
+
+// Registered template engines: when several engines provide a template of
+// the same name, ie. default.amber and default.html, the last one wins
+var templateEngines = []namedEngine{
+	{"amber", amberEngine{}},
+	{"go", goEngine{}},
+}
+
+// Register a template engine, an engine of the same name is replaced
+func RegisterTemplateEngine(name string, e TemplateEngine) {
+	for i, ne := range templateEngines {
+		if ne.name == name {
+			templateEngines[i].engine = e
+			return
+		}
+	}
+	templateEngines = append(templateEngines, namedEngine{name, e})
+}
+
+// Compile the templates of dir with all engines. A template is found by its
+// file name, ie. `Template: default.amber`, or by its name without extension.
+func (b *Builder) compileDir(dir string) (map[string]Template, error) {
+	res := make(map[string]Template)
+	funcs := b.templateFuncs()
+	for _, ne := range templateEngines {
+		tpls, err := ne.engine.Compile(dir, funcs)
+		if err != nil {
+			return nil, err
+		}
+		for fname, tpl := range tpls {
+			name := strings.TrimSuffix(fname, filepath.Ext(fname))
+			if _, ok := res[name]; ok {
+				DEBUG("template %s overrides %s", fname, name)
+			}
+			res[fname] = tpl
+			res[name] = tpl
+		}
+	}
+	return res, nil
+}
+
+// A template of a set, executed by name
+type namedTemplate struct {
+	set  *template.Template
+	name string
+}
+
+func (t namedTemplate) Execute(w io.Writer, data interface{}) error {
+	return t.set.ExecuteTemplate(w, t.name, data)
+}
+
+// The Amber templates (*.amber) of a directory
+type amberEngine struct{}
+
+func (amberEngine) Compile(dir string, funcs template.FuncMap) (map[string]Template, error) {
+	tpls, err := amber.CompileDir(dir, amber.DefaultDirOptions, amber.DefaultOptions)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]Template)
+	for name, tpl := range tpls {
+		// the builder's functions replace the global ones
+		tpl.Funcs(funcs)
+		res[name+".amber"] = namedTemplate{tpl, name + ".amber"}
+	}
+	return res, nil
+}
+
+// Sub-directories of the Go templates shared by all templates of a directory
+var goSharedDirs = []string{"layouts", "partials"}
+
+// Tell if a file is a Go template
+func isGoTemplate(name string) bool {
+	ext := filepath.Ext(name)
+	return ext == ".html" || ext == ".tmpl"
+}
+
+// The Go templates (*.html and *.tmpl) of a directory. The templates of the
+// layouts and partials sub-directories are available to all templates by
+// their path, ie. `{{template "partials/header.html" .}}`, and a template may
+// redefine the blocks of a layout.
+type goEngine struct{}
+
+func (goEngine) Compile(dir string, funcs template.FuncMap) (map[string]Template, error) {
+	shared := template.New("").Funcs(funcs)
+	for _, sub := range goSharedDirs {
+		err := filepath.Walk(filepath.Join(dir, sub), func(fpath string, fi os.FileInfo, err error) error {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			if err != nil || fi.IsDir() || !isGoTemplate(fpath) {
+				return err
+			}
+			rel, _ := filepath.Rel(dir, fpath)
+			return parseGoTemplate(shared.New(filepath.ToSlash(rel)), fpath)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]Template)
+	for _, fi := range files {
+		if fi.IsDir() || !isGoTemplate(fi.Name()) {
+			continue
+		}
+		// each template gets its own copy of the shared ones, whose blocks
+		// it may redefine
+		set, err := shared.Clone()
+		if err != nil {
+			return nil, err
+		}
+		if err := parseGoTemplate(set.New(fi.Name()), filepath.Join(dir, fi.Name())); err != nil {
+			return nil, err
+		}
+		res[fi.Name()] = namedTemplate{set, fi.Name()}
+	}
+	return res, nil
+}
+
+// Parse the template file fpath into tpl
+func parseGoTemplate(tpl *template.Template, fpath string) error {
+	content, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return err
+	}
+	_, err = tpl.Parse(string(content))
+	return err
+}
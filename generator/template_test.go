@@ -0,0 +1,62 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGoTemplates(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := writeTree(t, map[string]string{
+		"templates/layouts/base.html":   `<title>{{template "partials/title.html" .}}</title><main>{{block "content" .}}no content{{end}}</main>`,
+		"templates/partials/title.html": `{{.Meta.Title}}`,
+		"templates/default.html":        `{{template "layouts/base.html" .}}{{define "content"}}{{.Content}}{{fmttime .PubTime "2006"}}{{end}}`,
+		"templates/default.amber":       "| amber #{Meta.Title}\n",
+		"templates/plain.tmpl":          `{{template "layouts/base.html" .}}`,
+		"src/go.md":                     "---\nTitle: go\nDate: 2019-01-02\n---\nGo\n",
+		"src/amber.md":                  "---\nTitle: amber\nTemplate: default.amber\n---\nAmber\n",
+		"src/plain.md":                  "---\nTitle: plain\nTemplate: plain\n---\nPlain\n",
+	})
+	defer os.RemoveAll(dir)
+
+	b, err := New(Config{RootDir: dir, Src: "src", Out: "out", Template: "templates"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	for name, expect := range map[string]string{
+		"go":    "<title>go</title><main><p>Go</p>\n2019</main>",
+		"amber": "amber amber",
+		"plain": "<title>plain</title><main>no content</main>",
+	} {
+		content, err := ioutil.ReadFile(filepath.Join(dir, "out", name))
+		if err != nil {
+			t.Error(err)
+		} else if got := strings.TrimSpace(string(content)); got != expect {
+			t.Errorf("%s: expected %q, got %q", name, expect, got)
+		}
+	}
+
+	// execution errors are build errors
+	if err := ioutil.WriteFile(filepath.Join(dir, "templates", "broken.html"), []byte(`{{template "nosuch"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "src", "plain.md"), []byte("---\nTitle: plain\nTemplate: broken\n---\nPlain\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Build(); err == nil || !strings.Contains(err.Error(), "nosuch") {
+		t.Errorf("expected an undefined template error, got %v", err)
+	}
+}
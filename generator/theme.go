@@ -0,0 +1,171 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// The file describing a theme, in the theme directory
+const ThemeFile = "theme.json"
+
+// A theme provides templates, static files and default settings, which the
+// site's own templates, static files and settings override
+type Theme struct {
+	Name   string   `json:"-"` // the theme name, its directory in ThemesDir
+	Dir    string   `json:"-"` // the theme directory
+	Parent string   // the theme this one inherits from, if any
+	Ignore []string // patterns of static files not to copy, see StaticDir
+	Config Config   // default settings of the sites using the theme
+}
+
+// Templates directory of the theme
+func (th *Theme) TemplatesDir() string {
+	return filepath.Join(th.Dir, "templates")
+}
+
+// Static directory of the theme
+func (th *Theme) StaticDir() string {
+	return filepath.Join(th.Dir, "static")
+}
+
+// Data files directory of the theme
+func (th *Theme) DataDir() string {
+	return filepath.Join(th.Dir, "data")
+}
+
+// Load a theme of dir and its parents, return them from the farthest parent
+// to the theme itself
+func loadThemes(dir, name string) ([]*Theme, error) {
+	var themes []*Theme
+	seen := make(map[string]bool)
+	for name != "" {
+		if seen[name] {
+			return nil, fmt.Errorf("theme %s inherits from itself", name)
+		}
+		seen[name] = true
+
+		th := &Theme{Name: name, Dir: filepath.Join(dir, name)}
+		if fi, err := os.Stat(th.Dir); err != nil || !fi.IsDir() {
+			return nil, fmt.Errorf("theme %s not found in %s", name, dir)
+		}
+		if js, err := ioutil.ReadFile(filepath.Join(th.Dir, ThemeFile)); err == nil {
+			if err := json.Unmarshal(js, th); err != nil {
+				return nil, fmt.Errorf("theme %s: %v", name, err)
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+		themes = append([]*Theme{th}, themes...)
+		name = th.Parent
+	}
+	return themes, nil
+}
+
+// Settings a theme cannot change: the directories of the site
+var themeIgnored = map[string]bool{
+	"RootDir": true, "Src": true, "Out": true, "Template": true, "Static": true, "Data": true,
+	"ImageCache": true, "Theme": true, "ThemesDir": true, "Set": true,
+}
+
+// Fill the fields of cfg the site did not set (see Config.Set) with the ones
+// of defaults, maps are merged with the keys of cfg winning
+func mergeConfig(cfg, defaults Config) Config {
+	set := make(map[string]bool)
+	for _, name := range cfg.Set {
+		set[name] = true
+	}
+	v := reflect.ValueOf(&cfg).Elem()
+	d := reflect.ValueOf(defaults)
+	for i := 0; i < v.NumField(); i++ {
+		f, df := v.Field(i), d.Field(i)
+		name := v.Type().Field(i).Name
+		if themeIgnored[name] || df.IsZero() {
+			continue
+		}
+		switch {
+		case f.Kind() == reflect.Map:
+			merged := reflect.MakeMap(f.Type())
+			for _, m := range []reflect.Value{df, f} {
+				iter := m.MapRange()
+				for iter.Next() {
+					merged.SetMapIndex(iter.Key(), iter.Value())
+				}
+			}
+			f.Set(merged)
+		case cfg.Set == nil && f.IsZero(), cfg.Set != nil && !set[name]:
+			f.Set(df)
+		}
+	}
+	return cfg
+}
+
+// Use the configured theme: its settings are merged into the configuration,
+// its static directory goes before the site's ones
+func (b *Builder) applyTheme(root string) error {
+	cfg := b.Config
+	if cfg.Theme == "" {
+		return nil
+	}
+	themesDir := cfg.ThemesDir
+	if themesDir == "" {
+		themesDir = "themes"
+	}
+	themesDir, err := filepath.Abs(absDir(root, themesDir))
+	if err != nil {
+		return err
+	}
+	themes, err := loadThemes(themesDir, cfg.Theme)
+	if err != nil {
+		return err
+	}
+	for i := len(themes) - 1; i >= 0; i-- {
+		cfg = mergeConfig(cfg, themes[i].Config)
+	}
+
+	var static []StaticDir
+	for _, th := range themes {
+		if _, err := os.Stat(th.StaticDir()); err == nil {
+			static = append(static, StaticDir{Dir: th.StaticDir(), Ignore: th.Ignore})
+		}
+	}
+	cfg.Static = append(static, cfg.Static...)
+	b.Config, b.Themes = cfg, themes
+	return nil
+}
+
+// Return the directory of the templates: the site's one, or a temporary
+// directory merging the templates of the themes and of the site, which the
+// caller removes
+func (b *Builder) templatesDir() (string, error) {
+	if len(b.Themes) == 0 {
+		return b.TemplatesDir, nil
+	}
+	dir, err := ioutil.TempDir("", "jfever-templates")
+	if err != nil {
+		return "", err
+	}
+	layers := make([]string, 0, len(b.Themes)+1)
+	for _, th := range b.Themes {
+		layers = append(layers, th.TemplatesDir())
+	}
+	for _, layer := range append(layers, b.TemplatesDir) {
+		if _, err := os.Stat(layer); os.IsNotExist(err) {
+			continue
+		}
+		// files of the next layers override the ones of the previous
+		if err := copyFolder(layer, dir); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+	}
+	DEBUG("templates merged in %s", dir)
+	return dir, nil
+}
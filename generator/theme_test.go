@@ -0,0 +1,94 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadThemes(t *testing.T) {
+	dir := writeTree(t, map[string]string{
+		"a/theme.json":      `{"Parent": "b"}`,
+		"b/theme.json":      `{"Parent": "c", "Config": {"Markdown": "goldmark"}}`,
+		"c/templates/.keep": "",
+		"loop/theme.json":   `{"Parent": "loop"}`,
+		"orphan/theme.json": `{"Parent": "nosuch"}`,
+		"bad/theme.json":    `{"Parent": `,
+	})
+	defer os.RemoveAll(dir)
+
+	themes, err := loadThemes(dir, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, th := range themes {
+		names = append(names, th.Name)
+	}
+	if strings.Join(names, ",") != "c,b,a" || themes[1].Config.Markdown != "goldmark" || themes[0].Dir != filepath.Join(dir, "c") {
+		t.Errorf("unexpected themes %v", names)
+	}
+
+	for name, msg := range map[string]string{
+		"loop":   "theme loop inherits from itself",
+		"orphan": "theme nosuch not found",
+		"bad":    "theme bad: unexpected end of JSON input",
+	} {
+		if _, err := loadThemes(dir, name); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("%s: expected error %q, got %v", name, msg, err)
+		}
+	}
+}
+
+func TestMergeConfig(t *testing.T) {
+	site := Config{
+		SiteName: "Site",
+		Src:      "src",
+		CSSVars:  map[string]string{"primary": "red"},
+	}
+	theme := Config{
+		SiteName:     "Theme",
+		TagLine:      "Tag line",
+		Src:          "posts",
+		LineNumbers:  true,
+		ImageWidths:  []int{480},
+		CSSVars:      map[string]string{"primary": "blue", "accent": "green"},
+		Bundles:      map[string][]string{"css/site.css": {"css/a.css"}},
+		Fingerprint:  []string{"css"},
+		ImageQuality: 70,
+	}
+	got := mergeConfig(site, theme)
+	expect := Config{
+		SiteName:     "Site",
+		TagLine:      "Tag line",
+		Src:          "src",
+		LineNumbers:  true,
+		ImageWidths:  []int{480},
+		CSSVars:      map[string]string{"primary": "red", "accent": "green"},
+		Bundles:      map[string][]string{"css/site.css": {"css/a.css"}},
+		Fingerprint:  []string{"css"},
+		ImageQuality: 70,
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("expected %+v, got %+v", expect, got)
+	}
+	if len(site.CSSVars) != 1 {
+		t.Error("the site config should not be modified")
+	}
+
+	// the settings given by the site win, even when zero
+	theme.Minify, theme.ImageMaxWidth = true, 1200
+	site.Set = []string{"SiteName", "LineNumbers", "Minify", "ImageMaxWidth"}
+	got = mergeConfig(site, theme)
+	expect.Set = site.Set
+	expect.LineNumbers = false
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("expected %+v, got %+v", expect, got)
+	}
+}
@@ -0,0 +1,105 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// An entry of a page's table of contents, with its sub-entries
+type TOCEntry struct {
+	Level    int         // heading level, 1 to 6
+	ID       string      // anchor of the heading
+	Text     string      // heading text, without markup
+	Children []*TOCEntry // sub-headings
+}
+
+var (
+	rxHeading = regexp.MustCompile(`(?s)<h([1-6])([^>]*)>(.*?)</h[1-6]>`)
+	rxID      = regexp.MustCompile(`\sid="([^"]*)"`)
+	rxTag     = regexp.MustCompile(`<[^>]*>`)
+)
+
+// Give every heading of the rendered content a stable anchor, and build the
+// page's TOC from the headings between TOCMinLevel and TOCMaxLevel.
+// Setting `TOC: false` in the front matter leaves the content untouched.
+func (p *PAGE) buildTOC(content []byte) []byte {
+	if !p.Meta.Bool("TOC", true) {
+		return content
+	}
+	minLevel := p.Meta.Int("TOCMinLevel", 1)
+	maxLevel := p.Meta.Int("TOCMaxLevel", 6)
+
+	ids := make(map[string]int)
+	var stack []*TOCEntry
+	p.TOC = nil
+	return rxHeading.ReplaceAllFunc(content, func(h []byte) []byte {
+		m := rxHeading.FindSubmatch(h)
+		level := int(m[1][0] - '0')
+		attrs, inner := string(m[2]), string(m[3])
+		text := strings.TrimSpace(html.UnescapeString(rxTag.ReplaceAllString(inner, "")))
+
+		var id string
+		if idm := rxID.FindStringSubmatch(attrs); idm != nil {
+			id = idm[1]
+			ids[id]++
+		} else {
+			id = uniqueID(ids, headingID(text))
+			attrs += fmt.Sprintf(` id="%s"`, id)
+		}
+
+		if level >= minLevel && level <= maxLevel {
+			e := &TOCEntry{Level: level, ID: id, Text: text}
+			for len(stack) > 0 && stack[len(stack)-1].Level >= level {
+				stack = stack[:len(stack)-1]
+			}
+			if len(stack) == 0 {
+				p.TOC = append(p.TOC, e)
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, e)
+			}
+			stack = append(stack, e)
+		}
+		return []byte(fmt.Sprintf("<h%d%s>%s</h%d>", level, attrs, inner, level))
+	})
+}
+
+// Return an anchor from a heading text: lower case letters and digits,
+// words separated by dashes
+func headingID(text string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if dash && b.Len() > 0 {
+				b.WriteRune('-')
+			}
+			dash = false
+			b.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-' || r == '_':
+			dash = true
+		}
+	}
+	if b.Len() == 0 {
+		return "heading"
+	}
+	return b.String()
+}
+
+// Make id unique in a page by suffixing it with a counter
+func uniqueID(ids map[string]int, id string) string {
+	n, ok := ids[id]
+	ids[id] = n + 1
+	if !ok {
+		return id
+	}
+	return uniqueID(ids, fmt.Sprintf("%s-%d", id, n))
+}
@@ -0,0 +1,88 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Flatten a TOC as "level:id:text" lines, indented by depth
+func dumpTOC(entries []*TOCEntry, depth int) string {
+	var b strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&b, "%s%d:%s:%s\n", strings.Repeat(" ", depth), e.Level, e.ID, e.Text)
+		b.WriteString(dumpTOC(e.Children, depth+1))
+	}
+	return b.String()
+}
+
+func TestBuildTOC(t *testing.T) {
+	content := `<h1>Title</h1>
+<h2>First <em>part</em></h2>
+<h3 id="custom">Détails &amp; co</h3>
+<h2>Second part</h2>
+<h4 data-id="second-part">Deep</h4>
+<h2>Second part</h2>`
+
+	p := &PAGE{Meta: TemplateData{}}
+	res := string(p.buildTOC([]byte(content)))
+	for _, expect := range []string{
+		`<h1 id="title">Title</h1>`,
+		`<h2 id="first-part">First <em>part</em></h2>`,
+		`<h3 id="custom">Détails &amp; co</h3>`,
+		`<h2 id="second-part">Second part</h2>`,
+		`<h4 data-id="second-part" id="deep">Deep</h4>`,
+		`<h2 id="second-part-1">Second part</h2>`,
+	} {
+		if !strings.Contains(res, expect) {
+			t.Errorf("expected %s in:\n%s", expect, res)
+		}
+	}
+
+	expect := `1:title:Title
+ 2:first-part:First part
+  3:custom:Détails & co
+ 2:second-part:Second part
+  4:deep:Deep
+ 2:second-part-1:Second part
+`
+	if got := dumpTOC(p.TOC, 0); got != expect {
+		t.Errorf("expected TOC:\n%s\ngot:\n%s", expect, got)
+	}
+
+	// limit levels
+	p = &PAGE{Meta: TemplateData{"TOCMinLevel": "2", "TOCMaxLevel": "3"}}
+	p.buildTOC([]byte(content))
+	expect = `2:first-part:First part
+ 3:custom:Détails & co
+2:second-part:Second part
+2:second-part-1:Second part
+`
+	if got := dumpTOC(p.TOC, 0); got != expect {
+		t.Errorf("expected TOC:\n%s\ngot:\n%s", expect, got)
+	}
+
+	// opt-out
+	p = &PAGE{Meta: TemplateData{"TOC": "no"}}
+	if res := string(p.buildTOC([]byte(content))); res != content || p.TOC != nil {
+		t.Errorf("expected untouched content and no TOC, got %v:\n%s", p.TOC, res)
+	}
+}
+
+func TestHeadingID(t *testing.T) {
+	cases := map[string]string{
+		"Hello, World!":          "hello-world",
+		"  Été -- à Paris ":      "été-à-paris",
+		"snake_case and 2 words": "snake-case-and-2-words",
+		"!!!":                    "heading",
+	}
+	for text, expect := range cases {
+		if id := headingID(text); id != expect {
+			t.Errorf("%q: expected %s, got %s", text, expect, id)
+		}
+	}
+}
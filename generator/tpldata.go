@@ -1,4 +1,4 @@
-package main
+package generator
 /*
  * This is freesofware under 2-clause BSD license, See LICENSE file
  * Copyright (c) 2013, Martin Angers
@@ -12,18 +12,15 @@ import (
 	_ "os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
-
-	"github.com/russross/blackfriday"
 )
 
 var (
 	ErrEmptyPost          = fmt.Errorf("empty post file")
 	ErrInvalidFrontMatter = fmt.Errorf("invalid front matter")
 	ErrMissingFrontMatter = fmt.Errorf("missing front matter")
-	bfExtensions          = 0
-	bfRender              blackfriday.Renderer
 
 	// Lookup table to find the format based on the length of the date in the front matter
 	pubDtFmt = map[int]string{
@@ -40,6 +37,49 @@ var (
 // template to generate the static HTML file.
 type TemplateData map[string]string
 
+// Return the boolean value of key, or def if not set or invalid
+func (m TemplateData) Bool(key string, def bool) bool {
+	v, ok := m[key]
+	if !ok {
+		return def
+	}
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "yes", "on":
+		return true
+	case "no", "off":
+		return false
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(v))
+	if err != nil {
+		return def
+	}
+	return b
+}
+
+// Return the integer value of key, or def if not set or invalid
+func (m TemplateData) Int(key string, def int) int {
+	v, ok := m[key]
+	if !ok {
+		return def
+	}
+	i, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return def
+	}
+	return i
+}
+
+// Return the comma separated values of key, nil if not set
+func (m TemplateData) List(key string) []string {
+	var res []string
+	for _, v := range strings.Split(m[key], ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
 // Post data contains all the relevant information about a post (ie. meta data)
 // and also a TemplateData
 type PostData struct {
@@ -51,22 +91,6 @@ type PostData struct {
 	Content template.HTML
 }
 
-// Initialize a custom HTML render
-func initBF() {
-	bfExtensions |= blackfriday.EXTENSION_NO_INTRA_EMPHASIS
-	bfExtensions |= blackfriday.EXTENSION_TABLES
-	bfExtensions |= blackfriday.EXTENSION_FENCED_CODE
-	bfExtensions |= blackfriday.EXTENSION_AUTOLINK
-	bfExtensions |= blackfriday.EXTENSION_STRIKETHROUGH
-	bfExtensions |= blackfriday.EXTENSION_SPACE_HEADERS
-
-	htmlFlags := 0
-	htmlFlags |= blackfriday.HTML_USE_XHTML
-	htmlFlags |= blackfriday.HTML_USE_SMARTYPANTS
-	htmlFlags |= blackfriday.HTML_SMARTYPANTS_FRACTIONS
-	bfRender = blackfriday.HtmlRenderer(htmlFlags, "", "")
-}
-
 // All Posts readed, ready to be generated, make site Index (inter-link) here
 // `all` is an ordered array of all posts to generate
 // return the index post
@@ -87,6 +111,15 @@ func siteIndex(all []*PostData) (index int) {
 	return
 }
 
+// Parse a front matter date, its format is found by the length of the date
+func parseDate(dt string) (time.Time, error) {
+	f, ok := pubDtFmt[len(dt)]
+	if !ok {
+		return time.Time{}, fmt.Errorf("unknown date format: %s", dt)
+	}
+	return time.Parse(f, dt)
+}
+
 // Replace special characters to form a valid slug (post path)
 var rxSlug = regexp.MustCompile(`[^a-zA-Z\-_0-9]`)
 
@@ -96,16 +129,18 @@ func getSlug(fnm string) string {
 }
 
 // Read the front matter from the post. If there is no front matter, this is
-// not a valid post.
-func readFrontMatter(s *bufio.Scanner) (TemplateData, error) {
+// not a valid post. defaults are the site meta data, overridden by the front matter.
+func readFrontMatter(s *bufio.Scanner, defaults TemplateData) (TemplateData, error) {
 	// make a clone of SiteData
 	m := make(TemplateData)
-	for k, v := range SiteMeta.meta {
+	for k, v := range defaults {
 		m[k] = v
 	}
 
-	// defaut template name if not specified
-	m["Template"] = "default"
+	// defaut template name if not specified, nor by the defaults
+	if _, ok := m["Template"]; !ok {
+		m["Template"] = "default"
+	}
 
 	// scan the front matter
 	infm := false
@@ -0,0 +1,102 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadFrontMatter(t *testing.T) {
+	src := `
+---
+Title: A title: with colon
+Author:  Me
+---
+Body`
+	s := bufio.NewScanner(strings.NewReader(src))
+	m, err := readFrontMatter(s, TemplateData{"SiteName": "site", "Author": "nobody"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := TemplateData{
+		"SiteName": "site",
+		"Template": "default",
+		"Title":    "A title: with colon",
+		"Author":   "Me",
+	}
+	if len(m) != len(expect) {
+		t.Errorf("expected %v, got %v", expect, m)
+	}
+	for k, v := range expect {
+		if m[k] != v {
+			t.Errorf("%s: expected %q, got %q", k, v, m[k])
+		}
+	}
+	// the body is left to the scanner
+	if !s.Scan() || s.Text() != "Body" {
+		t.Errorf("expected body to follow the front matter, got %q", s.Text())
+	}
+}
+
+func TestReadFrontMatterErrors(t *testing.T) {
+	cases := map[string]error{
+		"":                          ErrEmptyPost,
+		"\n\n":                      ErrEmptyPost,
+		"---\nTitle: x\n":           ErrEmptyPost,
+		"# No front matter\n":       ErrMissingFrontMatter,
+		"---\nnot a key value\n---": ErrInvalidFrontMatter,
+	}
+	for src, expect := range cases {
+		_, err := readFrontMatter(bufio.NewScanner(strings.NewReader(src)), nil)
+		if err != expect {
+			t.Errorf("%q: expected %v, got %v", src, expect, err)
+		}
+	}
+}
+
+func TestGetSlug(t *testing.T) {
+	cases := map[string]string{
+		"002-meta-billet.md":  "002-meta-billet",
+		"007-implement.js.md": "007-implement-js",
+		"j'ai été là.md":      "j-ai--t--l-",
+		"no_extension":        "no_extension",
+		"Je Ne Suis Pas.md":   "Je-Ne-Suis-Pas",
+	}
+	for fnm, expect := range cases {
+		if slug := getSlug(fnm); slug != expect {
+			t.Errorf("%s: expected %s, got %s", fnm, expect, slug)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	cases := map[string]time.Time{
+		"2013-07-14":                time.Date(2013, 7, 14, 0, 0, 0, 0, time.UTC),
+		"2013-07-14 8h":             time.Date(2013, 7, 14, 8, 0, 0, 0, time.UTC),
+		"2013-07-14 15h":            time.Date(2013, 7, 14, 15, 0, 0, 0, time.UTC),
+		"2013-07-14 8:17":           time.Date(2013, 7, 14, 8, 17, 0, 0, time.UTC),
+		"2013-07-14 15:04":          time.Date(2013, 7, 14, 15, 4, 0, 0, time.UTC),
+		"2013-08-06T17:48:01-05:00": time.Date(2013, 8, 6, 22, 48, 1, 0, time.UTC),
+	}
+	for dt, expect := range cases {
+		got, err := parseDate(dt)
+		if err != nil {
+			t.Errorf("%s: %v", dt, err)
+			continue
+		}
+		if !got.Equal(expect) {
+			t.Errorf("%s: expected %v, got %v", dt, expect, got)
+		}
+	}
+
+	for _, dt := range []string{"", "14/07/2013", "2013-14-07"} {
+		if _, err := parseDate(dt); err == nil {
+			t.Errorf("%q: expected an error", dt)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// URL schemes of the pages
+const (
+	PlainURLs  = "plain"  // /sub/page, written to sub/page
+	HTMLURLs   = "html"   // /sub/page.html, written to sub/page.html
+	PrettyURLs = "pretty" // /sub/page/, written to sub/page/index.html
+)
+
+// Check the URL scheme of the pages, empty is the plain one
+func checkURLScheme(scheme string) error {
+	switch scheme {
+	case "", PlainURLs, HTMLURLs, PrettyURLs:
+		return nil
+	}
+	return fmt.Errorf("unknown URL scheme %q", scheme)
+}
+
+// Return the URL path of the page in the scheme of the site, or its URL key,
+// absolute or relative to its folder
+func (p *PAGE) pageURL() string {
+	if u := p.Meta["URL"]; u != "" {
+		return p.Folder.resolveURL(u)
+	}
+	u := path.Join(p.Folder.Path, p.DstName)
+	switch p.Site().b.Config.URLs {
+	case HTMLURLs:
+		return u + ".html"
+	case PrettyURLs:
+		return u + "/"
+	}
+	return u
+}
+
+// Return the clean URL path of u, absolute or relative to the folder
+func (folder *FOLDER) resolveURL(u string) string {
+	res := u
+	if !path.IsAbs(u) {
+		res = path.Join(folder.Path, u)
+	}
+	res = path.Clean(res)
+	if strings.HasSuffix(u, "/") && res != "/" {
+		res += "/"
+	}
+	return res
+}
+
+// Check that the URL path u, absolute or relative to the folder, does not
+// go above the root of the site
+func (folder *FOLDER) checkURL(u string) error {
+	full := u
+	if !path.IsAbs(u) {
+		full = folder.Path + "/" + u
+	}
+	depth := 0
+	for _, elem := range strings.Split(full, "/") {
+		switch elem {
+		case "", ".":
+		case "..":
+			if depth--; depth < 0 {
+				return fmt.Errorf("URL %s outside of the site", u)
+			}
+		default:
+			depth++
+		}
+	}
+	return nil
+}
+
+// Return the URL of the directory relative links of the page are resolved
+// against by browsers, ie. /sub/ for /sub/page and /sub/page/ for a pretty
+// URL
+func (p *PAGE) baseURL() string {
+	u := p.URL()
+	return u[:strings.LastIndex(u, "/")+1]
+}
+
+// Return the path of the generated file of the page, relative to Out, ie.
+// /sub/page/index.html
+func (p *PAGE) outPath() string {
+	return outFile(p.URL())
+}
+
+// Return the path of the file served at the URL path u, relative to Out
+func outFile(u string) string {
+	if strings.HasSuffix(u, "/") {
+		return u + "index.html"
+	}
+	return u
+}
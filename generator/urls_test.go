@@ -0,0 +1,149 @@
+package generator
+/*
+ * This is freesofware under 2-clause BSD license, See LICENSE file
+ * (C)opyright 2018,2019 juju
+ */
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestURLSchemes(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := writeTree(t, map[string]string{
+		"templates/default.html": `{{.URL}} {{.Content}}`,
+		"src/index.md":           "---\nTitle: home\nIndex: yes\nDate: 2019-01-01\n---\n[post](sub/post.md)\n",
+		"src/sub/post.md":        "---\nTitle: post\nDate: 2019-02-01\n---\n[about](../about.md)\n",
+		"src/about.md":           "---\nTitle: about\nURL: /me/\nDate: 2019-03-01\n---\nMe\n",
+	})
+	defer os.RemoveAll(dir)
+
+	for scheme, expect := range map[string]map[string]string{
+		PlainURLs: {
+			"index.html":    `/index <p><a href="/sub/post">post</a></p>`,
+			"sub/post":      `/sub/post <p><a href="/me/">about</a></p>`,
+			"me/index.html": `/me/ <p>Me</p>`,
+		},
+		HTMLURLs: {
+			"index.html":    `/index.html <p><a href="/sub/post.html">post</a></p>`,
+			"sub/post.html": `/sub/post.html <p><a href="/me/">about</a></p>`,
+			"me/index.html": `/me/ <p>Me</p>`,
+		},
+		PrettyURLs: {
+			"index/index.html":    `/index/ <p><a href="/sub/post/">post</a></p>`,
+			"index.html":          `/index/ <p><a href="/sub/post/">post</a></p>`,
+			"sub/post/index.html": `/sub/post/ <p><a href="/me/">about</a></p>`,
+		},
+	} {
+		os.RemoveAll(filepath.Join(dir, "out"))
+		b, err := New(Config{RootDir: dir, Src: "src", Out: "out", Template: "templates", BaseURL: "http://example.com", URLs: scheme})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.Build(); err != nil {
+			t.Fatalf("%s: %v", scheme, err)
+		}
+		for name, content := range expect {
+			got, err := ioutil.ReadFile(filepath.Join(dir, "out", filepath.FromSlash(name)))
+			if err != nil {
+				t.Errorf("%s: %v", scheme, err)
+			} else if strings.TrimSpace(string(got)) != content {
+				t.Errorf("%s: %s: expected %q, got %q", scheme, name, content, got)
+			}
+		}
+		feed, _ := ioutil.ReadFile(filepath.Join(dir, "out", "rss"))
+		if link := "<link>http://example.com" + strings.Fields(expect["index.html"])[0] + "</link>"; !strings.Contains(string(feed), link) {
+			t.Errorf("%s: expected %s in the feed", scheme, link)
+		}
+
+		// a page of another folder is kept
+		if _, err := b.Build(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "out", "me", "index.html")); err != nil {
+			t.Errorf("%s: %v", scheme, err)
+		}
+	}
+
+	if _, err := New(Config{URLs: "ugly"}); err == nil {
+		t.Error("expected an error on an unknown URL scheme")
+	}
+}
+
+func TestURLRelativeLinks(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := writeTree(t, map[string]string{
+		"templates/default.html": `{{.Content}}`,
+		"src/sub/post.md":        "---\nTitle: post\n---\n[notes](notes.txt?v=1) ![cat](../img/cat.png) [site](http://example.com/x) [home](/)\n",
+		"src/sub/moved.md":       "---\nTitle: moved\nURL: /elsewhere/moved\n---\n[notes](notes.txt)\n",
+		"src/sub/notes.txt":      "notes",
+	})
+	defer os.RemoveAll(dir)
+
+	for scheme, expect := range map[string]map[string]string{
+		PlainURLs: {
+			"sub/post":        `<p><a href="notes.txt?v=1">notes</a> <img src="../img/cat.png" alt="cat" /> <a href="http://example.com/x">site</a> <a href="/">home</a></p>`,
+			"elsewhere/moved": `<p><a href="/sub/notes.txt">notes</a></p>`,
+		},
+		PrettyURLs: {
+			"sub/post/index.html": `<p><a href="/sub/notes.txt?v=1">notes</a> <img src="/img/cat.png" alt="cat" /> <a href="http://example.com/x">site</a> <a href="/">home</a></p>`,
+			"elsewhere/moved":     `<p><a href="/sub/notes.txt">notes</a></p>`,
+		},
+	} {
+		os.RemoveAll(filepath.Join(dir, "out"))
+		b, err := New(Config{RootDir: dir, Src: "src", Out: "out", Template: "templates", URLs: scheme})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.Build(); err != nil {
+			t.Fatalf("%s: %v", scheme, err)
+		}
+		for name, content := range expect {
+			got, err := ioutil.ReadFile(filepath.Join(dir, "out", filepath.FromSlash(name)))
+			if err != nil {
+				t.Errorf("%s: %v", scheme, err)
+			} else if strings.TrimSpace(string(got)) != content {
+				t.Errorf("%s: %s: expected %q, got %q", scheme, name, content, got)
+			}
+		}
+	}
+}
+
+func TestURLOutsideSite(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	for _, u := range []string{"/../../x", "../../x/", "/a/../../x"} {
+		dir := writeTree(t, map[string]string{
+			"templates/default.html": `{{.Content}}`,
+			"src/sub/page.md":        "---\nTitle: page\nURL: " + u + "\n---\nPage\n",
+		})
+		b, err := New(Config{RootDir: dir, Src: "src", Out: "out", Template: "templates"})
+		if err == nil {
+			_, err = b.Build()
+		}
+		if err == nil {
+			t.Errorf("%s: expected an error", u)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "x")); !os.IsNotExist(err) {
+			t.Errorf("%s: unexpected file outside of out", u)
+		}
+		os.RemoveAll(dir)
+	}
+
+	folder := &FOLDER{Path: "/sub"}
+	for u, expect := range map[string]string{"/a/../b/": "/b/", "../x": "/x", "./y/.": "/sub/y", "/": "/"} {
+		if got := folder.resolveURL(u); got != expect {
+			t.Errorf("%s: expected %s, got %s", u, expect, got)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+module git.inexacte.science/juju/jfever
+
+go 1.23.0
+
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/alecthomas/chroma/v2 v2.23.1
+	github.com/eknkc/amber v0.0.0-20171010120322-cdade1c07385
+	github.com/jessevdk/go-flags v1.6.1
+	github.com/radovskyb/watcher v1.0.7
+	github.com/russross/blackfriday v1.6.0
+	github.com/tdewolff/minify/v2 v2.21.0
+	github.com/yuin/goldmark v1.7.8
+	golang.org/x/image v0.25.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/dlclark/regexp2 v1.11.5 // indirect
+	github.com/tdewolff/parse/v2 v2.7.17 // indirect
+	golang.org/x/sys v0.25.0 // indirect
+)
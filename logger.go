@@ -8,28 +8,17 @@ import (
 	"log"
 	"os"
 	"runtime/debug"
-)
-
-func INFO(format string, args ...interface{}) {
-	log.Printf("[INFO]"+format, args...)
-}
-
-func WARN(format string, args ...interface{}) {
-	if Options.Debug {
-		log.Printf("[WARN]"+format, args...)
-	}
-}
 
-func DEBUG(format string, args ...interface{}) {
-	if Options.Debug {
-		log.Printf("[DEBUG]"+format, args...)
-	}
-}
+	"git.inexacte.science/juju/jfever/generator"
+)
 
-func ERROR(format string, args ...interface{}) {
-	debug.PrintStack()
-	log.Printf("[ERROR]"+format, args...)
-}
+// The command shares the generator's logger
+var (
+	INFO  = generator.INFO
+	WARN  = generator.WARN
+	DEBUG = generator.DEBUG
+	ERROR = generator.ERROR
+)
 
 func FATAL(format string, args ...interface{}) {
 	log.Printf("[FATAL]"+format, args...)
@@ -6,130 +6,219 @@ package main
  */
 
 import (
-	"net/url"
 	"os"
-	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/jessevdk/go-flags"
+
+	"git.inexacte.science/juju/jfever/generator"
 )
 
 // This structure holds the command-line options.
 type options struct {
-	Port             int    `short:"p" long:"port" description:"the port to use for the web server" default:"9000"`
-	GenOnly          bool   `short:"g" long:"generate-only" description:"generate the static site and exit"`
-	NoGen            bool   `short:"G" long:"no-generation" description:"when set, the site is not automatically generated"`
-	SiteName         string `short:"n" long:"site-name" description:"the name of the site" default:"Site Name"`
-	TagLine          string `short:"t" long:"tag-line" description:"the site's tag line"`
-	RecentPostsCount int    `short:"r" long:"recent-posts" description:"the number of recent posts to send to the templates" default:"5"`
-	BaseURL          string `short:"b" long:"base-url" description:"the base URL of the web site" default:"http://localhost"`
-	Debug            bool   `short:"d" long:"debug" description:"Enable debug output"`
-	Src              string `short:"s" long:"src" description:"the source sub-dir name" default:"src"`
-	Out              string `short:"o" long:"out" description:"the output sub-dir name" default:"out"`
-	Template         string `short:"a" long:"template" description:"the template sub-dir name" default:"templates"`
-	Static           string `short:"i" long:"static" description:"static content to be copied to Out/" default:"static"`
-}
-
-type siteMeta struct {
-	meta        TemplateData
-	recentPosts int
+	Port             int               `short:"p" long:"port" description:"the port to use for the web server" default:"9000"`
+	GenOnly          bool              `short:"g" long:"generate-only" description:"generate the static site and exit"`
+	NoGen            bool              `short:"G" long:"no-generation" description:"when set, the site is not automatically generated"`
+	SiteName         string            `short:"n" long:"site-name" description:"the name of the site" default:"Site Name"`
+	TagLine          string            `short:"t" long:"tag-line" description:"the site's tag line"`
+	RecentPostsCount int               `short:"r" long:"recent-posts" description:"the number of recent posts to send to the templates" default:"5"`
+	FeedItems        int               `long:"feed-items" description:"the number of items of the feeds" default:"20"`
+	SummaryWords     int               `long:"summary-words" description:"the max number of words of the page summaries" default:"70"`
+	PageSize         int               `long:"page-size" description:"the number of pages listed by a page of the listings" default:"10"`
+	BaseURL          string            `short:"b" long:"base-url" description:"the base URL of the web site" default:"http://localhost"`
+	Debug            bool              `short:"d" long:"debug" description:"Enable debug output"`
+	Src              string            `short:"s" long:"src" description:"the source sub-dir name" default:"src"`
+	Out              string            `short:"o" long:"out" description:"the output sub-dir name" default:"out"`
+	Template         string            `short:"a" long:"template" description:"the template sub-dir name" default:"templates"`
+	ListTemplate     string            `long:"list-template" description:"the template of the folders without index page" default:"list"`
+	URLs             string            `long:"urls" description:"the URL scheme of the pages: plain (/page), html (/page.html) or pretty (/page/)" default:"plain"`
+	Redirects        []string          `long:"redirects" description:"format of the redirect map of the aliases: nginx, apache or netlify (repeatable)"`
+	Static           []string          `short:"i" long:"static" description:"static root copied to Out/, with patterns of ignored files, ie. theme/static:*.psd,drafts/* (repeatable, later roots override earlier ones)" default:"static"`
+	Data             string            `long:"data" description:"the data files sub-dir name" default:"data"`
+	Theme            string            `short:"T" long:"theme" description:"the theme of the site"`
+	ThemesDir        string            `long:"themes-dir" description:"the directory of the themes" default:"themes"`
+	Markdown         string            `short:"m" long:"markdown" description:"the markdown engine and its options, default to blackfriday"`
+	Highlight        string            `long:"highlight" description:"the style to highlight fenced code with, ie. github"`
+	HighlightClasses bool              `long:"highlight-classes" description:"highlight with CSS classes instead of inline styles"`
+	LineNumbers      bool              `long:"line-numbers" description:"number the lines of highlighted code"`
+	HighlightCSS     bool              `long:"highlight-css" description:"write the highlighting stylesheet into the static dir and exit"`
+	Bundles          map[string]string `long:"bundle" description:"bundle of static files, ie. css/site.css:css/a.css,css/b.css (repeatable)"`
+	Minify           bool              `long:"minify" description:"minify CSS, JS, SVG and the generated HTML"`
+	PreprocessCSS    bool              `long:"preprocess-css" description:"inline the imports and replace the variables of CSS files"`
+	CSSVars          map[string]string `long:"css-var" description:"CSS variable, ie. primary:#336699 (repeatable)"`
+	Fingerprint      []string          `long:"fingerprint" description:"extension of the static assets to fingerprint, ie. css (repeatable)"`
+	ImageWidths      []int             `long:"image-width" description:"width of the srcset variants of images (repeatable)"`
+	ImageMaxWidth    int               `long:"image-max-width" description:"resize wider images to this width"`
+	ImageQuality     int               `long:"image-quality" description:"JPEG quality of resized images" default:"85"`
+	ImageCache       string            `long:"image-cache" description:"cache directory of processed images" default:".imagecache"`
+
+	CheckLinks      bool          `short:"c" long:"check-links" description:"check the links of the generated site and exit"`
+	CheckExternal   bool          `long:"check-external" description:"also check external links"`
+	LinkConcurrency int           `long:"link-concurrency" description:"max simultaneous external link checks" default:"8"`
+	LinkHostDelay   time.Duration `long:"link-host-delay" description:"min delay between two checks on the same host" default:"1s"`
+	LinkCache       string        `long:"link-cache" description:"file caching external link checks between runs"`
+	LinkAllow       []string      `long:"link-allow" description:"external URL prefix or host not to check (repeatable)"`
 }
 
 var (
 	// The one and only Options parsed from the command-line
-	Options      options
-	RootDir      string   // Start root directory
-	PublicDir    string   // Public directory path
-	PostsDir     string   // Posts directory path
-	TemplatesDir string   // Templates directory path
-	StaticDirs   string   // Static contents path
-	RssURL       string   // The RSS feed URL, parsed only once and stored for convenience
-	SiteMeta     siteMeta // The site meta data can be used by posts
-	Debug        bool     // Enable debug output
+	Options options
+	builder *generator.Builder // The site builder configured from Options
 )
 
 func init() {
 	// Parse arguments
-	_, err := flags.Parse(&Options)
+	// --minify=false and such override the settings of a theme
+	parser := flags.NewParser(&Options, flags.Default|flags.AllowBoolValues)
+	_, err := parser.Parse()
 	if err != nil {
 		FATAL("A:%v", err.Error())
 	}
-
-	// RootDir is where arg[0] is launched
-	RootDir, err := os.Getwd()
+	generator.Debug = Options.Debug
+
+	// Directories are absolute or relative to where arg[0] is launched
+	builder, err = generator.New(generator.Config{
+		SiteName:         Options.SiteName,
+		TagLine:          Options.TagLine,
+		RecentPostsCount: Options.RecentPostsCount,
+		FeedItems:        Options.FeedItems,
+		SummaryWords:     Options.SummaryWords,
+		PageSize:         Options.PageSize,
+		BaseURL:          Options.BaseURL,
+		Src:              Options.Src,
+		Out:              Options.Out,
+		Template:         Options.Template,
+		ListTemplate:     Options.ListTemplate,
+		URLs:             Options.URLs,
+		Redirects:        Options.Redirects,
+		Static:           staticDirs(Options.Static),
+		Data:             Options.Data,
+		Theme:            Options.Theme,
+		ThemesDir:        Options.ThemesDir,
+		Markdown:         Options.Markdown,
+		Highlight:        Options.Highlight,
+		HighlightClasses: Options.HighlightClasses,
+		LineNumbers:      Options.LineNumbers,
+		Fingerprint:      Options.Fingerprint,
+		Bundles:          bundles(Options.Bundles),
+		Minify:           Options.Minify,
+		PreprocessCSS:    Options.PreprocessCSS,
+		CSSVars:          Options.CSSVars,
+		ImageWidths:      Options.ImageWidths,
+		ImageMaxWidth:    Options.ImageMaxWidth,
+		ImageQuality:     Options.ImageQuality,
+		ImageCache:       Options.ImageCache,
+		Set:              setOptions(parser),
+	})
 	if err != nil {
 		FATAL(err.Error())
 	}
+}
 
-	// Init directories with absolut path or relatives to RootDir
-	// PublicDir is where the web pages are stored
-	if Options.Out[0] == '/' {
-		PublicDir = Options.Out
-	} else {
-		PublicDir = filepath.Join(RootDir, Options.Out)
-	}
-
-	// PostsDir is where the author's *.md are stored
-	if Options.Src[0] == '/' {
-		PostsDir = Options.Src
-	} else {
-		PostsDir = filepath.Join(RootDir, Options.Src)
-	}
-
-	// TemplatesDir is where templates stays
-	if Options.Template[0] == '/' {
-		TemplatesDir = Options.Template
-	} else {
-		TemplatesDir = filepath.Join(RootDir, Options.Template)
-	}
-
-	// TemplatesDir is where templates stays
-	if Options.Static[0] == '/' {
-		StaticDirs = Options.Static
-	} else {
-		StaticDirs = filepath.Join(RootDir, Options.Static)
+// Return the names of the options given on the command-line
+func setOptions(parser *flags.Parser) []string {
+	set := []string{}
+	for _, group := range parser.Groups() {
+		for _, opt := range group.Options() {
+			if opt.IsSet() && !opt.IsSetDefault() {
+				set = append(set, opt.Field().Name)
+			}
+		}
 	}
-
-	initBF()
+	return set
 }
 
-func storeRssURL() {
-	b, err := url.Parse(Options.BaseURL)
-	if err != nil {
-		FATAL(err.Error())
-	}
-	r, err := b.Parse("/rss")
-	if err != nil {
-		FATAL(err.Error())
+// Split the static roots given on the command-line in their directory and
+// ignored patterns
+func staticDirs(opts []string) []generator.StaticDir {
+	var res []generator.StaticDir
+	for _, opt := range opts {
+		sd := generator.StaticDir{Dir: opt}
+		if i := strings.LastIndex(opt, ":"); i > 1 {
+			sd.Dir = opt[:i]
+			for _, p := range strings.Split(opt[i+1:], ",") {
+				if p = strings.TrimSpace(p); p != "" {
+					sd.Ignore = append(sd.Ignore, p)
+				}
+			}
+		}
+		res = append(res, sd)
 	}
-	RssURL = r.String()
+	return res
 }
 
-func copyMeta() {
-	SiteMeta.recentPosts = Options.RecentPostsCount
-	SiteMeta.meta = make(TemplateData)
-	SiteMeta.meta["BaseURL"] = Options.BaseURL
-	SiteMeta.meta["SiteName"] = Options.SiteName
-	SiteMeta.meta["TagLine"] = Options.TagLine
-	SiteMeta.meta["RssURL"] = RssURL
+// Split the files of the bundles given on the command-line
+func bundles(opts map[string]string) map[string][]string {
+	res := make(map[string][]string)
+	for name, files := range opts {
+		for _, f := range strings.Split(files, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				res[name] = append(res[name], f)
+			}
+		}
+	}
+	return res
 }
 
 func main() {
 	INFO("Start program......")
-	storeRssURL()
-	copyMeta()
+	if Options.HighlightCSS {
+		fpath, err := builder.WriteHighlightCSS()
+		if err != nil {
+			FATAL(err.Error())
+		}
+		INFO("Stylesheet written to %s", fpath)
+		return
+	}
 	if !Options.NoGen {
 		// Generate the site
-		if err := generateSite(); err != nil {
+		if err := build(); err != nil {
 			INFO("generateSite failed: %v", err)
 		}
 		// Terminate if set to generate only
 		if Options.GenOnly {
 			return
 		}
+	}
+	if Options.CheckLinks {
+		checkLinks()
+		return
+	}
+	if !Options.NoGen {
 		// Start the watcher
-		go beginWatch(TemplatesDir, PostsDir, StaticDirs)
+		paths := []string{builder.TemplatesDir, builder.PostsDir}
+		if _, err := os.Stat(builder.DataDir); err == nil {
+			paths = append(paths, builder.DataDir)
+		}
+		for _, th := range builder.Themes {
+			paths = append(paths, th.Dir)
+		}
+		for _, sd := range builder.StaticDirs {
+			paths = append(paths, sd.Dir)
+		}
+		go beginWatch(paths...)
 
 		// Start the web server
 		run()
 	}
 }
+
+// Check the links of the generated site, exit with an error if any is broken
+func checkLinks() {
+	report, err := builder.CheckLinks(generator.LinkCheckConfig{
+		External:    Options.CheckExternal,
+		Concurrency: Options.LinkConcurrency,
+		HostDelay:   Options.LinkHostDelay,
+		CacheFile:   Options.LinkCache,
+		Allow:       Options.LinkAllow,
+	})
+	if err != nil {
+		FATAL(err.Error())
+	}
+	report.Write(os.Stdout)
+	if n := report.Count(); n > 0 {
+		FATAL("%d broken link(s)", n)
+	}
+	INFO("No broken link")
+}
@@ -1,4 +1,4 @@
 #! /bin/bash
 
 cd examples/amber
-../../jfever -n DemoSite -t Tagline  -s posts -o out -a templates -d  
+../../jfever -n DemoSite -t Tagline  -s posts -o out -a templates -d --bundle=css/site.css:css/pure-min.css,css/main.css,css/fontello.css,css/local.css
@@ -4,30 +4,153 @@ package main
  */
 
 import (
+	"compress/gzip"
 	"fmt"
 	"net/http"
 	_ "net/http/pprof"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
-
-	"github.com/PuerkitoBio/ghost/handlers"
 )
 
+// The redirects of the aliases of the last build, by alias
+var aliases struct {
+	sync.RWMutex
+	urls map[string]string
+}
+
+// Build the site, and keep its redirects for the server
+func build() error {
+	res, err := builder.Build()
+	if res != nil {
+		aliases.Lock()
+		aliases.urls = res.Redirects
+		aliases.Unlock()
+	}
+	return err
+}
+
+// Redirect the aliases of the pages permanently, as the redirect maps of the
+// production server do, instead of serving their stubs
+func redirectHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		aliases.RLock()
+		to, ok := aliases.urls[r.URL.Path]
+		aliases.RUnlock()
+		if ok {
+			http.Redirect(w, r, to, http.StatusMovedPermanently)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Serve the favicon, cached for maxAge
+func faviconHandler(h http.Handler, fpath string, maxAge time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/favicon.ico" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+		http.ServeFile(w, r, fpath)
+	})
+}
+
+// Answer a 500 error instead of dropping the connection when h panics
+func panicHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				ERROR("%s %s: %v", r.Method, r.URL, err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// A response writer keeping the status of the response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Log the requests, with the status and duration of their response
+func logHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{w, http.StatusOK}
+		h.ServeHTTP(sw, r)
+		INFO("%s %s %d %v", r.Method, r.URL, sw.status, time.Since(start))
+	})
+}
+
+// A response writer compressing the body of the response, unless it has
+// none or is already encoded
+type gzipWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (w *gzipWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if status != http.StatusNoContent && status != http.StatusNotModified && w.Header().Get("Content-Encoding") == "" {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Del("Content-Length")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if w.gz == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.gz.Write(b)
+}
+
+// Compress the responses to the clients accepting gzip
+func gzipHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			h.ServeHTTP(w, r)
+			return
+		}
+		// ranges of the compressed body are not supported
+		r.Header.Del("Range")
+		gw := &gzipWriter{ResponseWriter: w}
+		h.ServeHTTP(gw, r)
+		if gw.gz != nil {
+			gw.gz.Close()
+		}
+	})
+}
+
 // Start serving the blog.
 func run() {
 	var (
-		faviconPath  = filepath.Join(PublicDir, "favicon.ico")
+		faviconPath  = filepath.Join(builder.PublicDir, "favicon.ico")
 		faviconCache = 2 * 24 * time.Hour
 	)
 
-	h := handlers.FaviconHandler(
-		handlers.PanicHandler(
-			handlers.LogHandler(
-				handlers.GZIPHandler(
-					http.FileServer(http.Dir(PublicDir)),
-					nil),
-				handlers.NewLogOptions(nil, handlers.Ldefault)),
-			nil),
+	h := faviconHandler(
+		panicHandler(
+			logHandler(
+				gzipHandler(
+					redirectHandler(http.FileServer(http.Dir(builder.PublicDir)))))),
 		faviconPath,
 		faviconCache)
 
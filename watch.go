@@ -68,5 +68,7 @@ func fwHandler() {
 
 func rebuild() {
 	DEBUG("REBUILD...")
-	generateSite()
+	if err := build(); err != nil {
+		WARN("rebuild failed: %v", err)
+	}
 }